@@ -1,61 +1,164 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/config"
 	"github.com/najwa/product-catalog-api/internal/db"
 	"github.com/najwa/product-catalog-api/internal/handlers"
-	"github.com/najwa/product-catalog-api/internal/middleware"
+	"github.com/najwa/product-catalog-api/internal/revocation"
 )
 
 func main() {
-	// Parse command-line flags
-	port := flag.String("port", "8080", "Port to listen on")
-	dbPath := flag.String("db", "./product_catalog.db", "Path to SQLite database file")
-	flag.Parse()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the server and blocks until it fails or a shutdown signal is
+// received. On shutdown, in-flight requests are drained before background
+// workers are stopped and the database is closed.
+func run() error {
+	// Load the configuration from the config file, environment and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
 
 	// Initialize the database
-	absDBPath, err := filepath.Abs(*dbPath)
+	source, err := cfg.Database.Source()
+	if err != nil {
+		return err
+	}
+
+	options, err := cfg.StoreOptions()
+	if err != nil {
+		return err
+	}
+
+	store, err := db.Open(cfg.Database.Driver, source, options)
 	if err != nil {
-		log.Fatalf("Error resolving database path: %v", err)
+		return fmt.Errorf("error initializing database: %w", err)
 	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
+			return
+		}
+		log.Println("Database closed")
+	}()
 
-	err = db.Initialize(absDBPath)
+	// Configure authentication
+	keySet, err := cfg.Auth.KeySet()
+	if err != nil {
+		return fmt.Errorf("error loading JWT signing keys: %w", err)
+	}
+	issuer, err := auth.NewIssuer(auth.Config{
+		AccessTokenExpiry:  time.Duration(cfg.Auth.AccessTokenExpiry),
+		RefreshTokenExpiry: time.Duration(cfg.Auth.RefreshTokenExpiry),
+		Keys:               keySet,
+	})
 	if err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+		return fmt.Errorf("error configuring authentication: %w", err)
 	}
-	defer db.Close()
 
-	// Set up routes
-	setupRoutes()
+	// Load revoked tokens and start refreshing them and purging expired ones
+	revocations, err := revocation.New(store, store, issuer.TokenExpiry())
+	if err != nil {
+		return fmt.Errorf("error initializing token revocation: %w", err)
+	}
+	defer revocations.Close()
+
+	// Set up the handlers
+	h := handlers.New(store, issuer, revocations, handlers.Config{
+		DefaultPageSize: cfg.API.DefaultPageSize,
+		MaxPageSize:     cfg.API.MaxPageSize,
+		CursorKey:       cfg.API.CursorKey(),
+	})
 
 	// Start the server
-	log.Printf("Server starting on port %s...", *port)
-	log.Fatal(http.ListenAndServe(":"+*port, nil))
-}
+	server := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      h.Router(),
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func setupRoutes() {
-	// Public routes
-	http.HandleFunc("/login", handlers.LoginHandler)
-	http.HandleFunc("/products", handlers.ProductsHandler)
-
-	// Protected routes
-	// Create a subrouter for protected routes
-	favoritesHandler := http.HandlerFunc(handlers.AddFavoriteHandler)
-	getFavoritesHandler := http.HandlerFunc(handlers.GetFavoritesHandler)
-
-	// Apply auth middleware to protected routes
-	http.Handle("/favorites", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			favoritesHandler.ServeHTTP(w, r)
-		case http.MethodGet:
-			getFavoritesHandler.ServeHTTP(w, r)
-		default:
-			w.WriteHeader(http.StatusMethodNotAllowed)
+	// Reload the signing keys on SIGHUP, e.g. after adding or rotating keys
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				reloadKeys(issuer)
+			case <-ctx.Done():
+				return
+			}
 		}
-	})))
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s...", cfg.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("error running server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and wait for in-flight requests to finish
+	log.Println("Shutting down, draining connections...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining connections, closing them: %v", err)
+		server.Close()
+	}
+	log.Println("Server stopped")
+
+	// Background workers and then the database are stopped by the deferred calls above
+	return nil
+}
+
+// reloadKeys loads the configuration again and makes the issuer sign and
+// validate tokens with its signing keys. The current keys are kept if the
+// configuration is invalid or has no keys, since ephemeral keys would
+// invalidate every token issued so far.
+func reloadKeys(issuer *auth.Issuer) {
+	cfg, err := config.Load(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+	if err != nil {
+		log.Printf("Error reloading configuration, keeping the current JWT signing keys: %v", err)
+		return
+	}
+	if !cfg.Auth.KeysConfigured() {
+		log.Println("No JWT signing keys configured, keeping the current ones")
+		return
+	}
+
+	keySet, err := cfg.Auth.KeySet()
+	if err != nil {
+		log.Printf("Error reloading JWT signing keys, keeping the current ones: %v", err)
+		return
+	}
+	issuer.SetKeySet(keySet)
+	log.Println("JWT signing keys reloaded")
 }
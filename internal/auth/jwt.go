@@ -5,43 +5,103 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var (
-	// Secret key for signing JWT tokens
-	// In a production environment, this should be stored securely
-	jwtSecret = []byte("your-secret-key-here")
+// Config configures how tokens are issued and validated. Zero values select
+// the defaults.
+type Config struct {
+	// AccessTokenExpiry is how long access tokens are valid for, 15 minutes
+	// by default; clients renew them with a refresh token
+	AccessTokenExpiry time.Duration
 
-	// Token expiry time (24 hours)
-	tokenExpiry = 24 * time.Hour
-)
+	// RefreshTokenExpiry is how long refresh tokens are valid for, 30 days by
+	// default. Each refresh rotates the token, so this is the maximum time a
+	// session can stay idle.
+	RefreshTokenExpiry time.Duration
+
+	// Keys sign and validate tokens; an ephemeral random HMAC key by default
+	Keys *KeySet
+}
+
+// Issuer issues and validates tokens according to its configuration
+type Issuer struct {
+	tokenExpiry        time.Duration
+	refreshTokenExpiry time.Duration
+
+	// keys may be replaced while requests are signing and validating tokens
+	keys atomic.Pointer[KeySet]
+}
+
+// NewIssuer creates an issuer with the given configuration
+func NewIssuer(cfg Config) (*Issuer, error) {
+	if cfg.AccessTokenExpiry <= 0 {
+		cfg.AccessTokenExpiry = 15 * time.Minute
+	}
+	if cfg.RefreshTokenExpiry <= 0 {
+		cfg.RefreshTokenExpiry = 30 * 24 * time.Hour
+	}
+	if cfg.Keys == nil {
+		ks, err := NewEphemeralKeySet()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Keys = ks
+	}
+
+	i := &Issuer{tokenExpiry: cfg.AccessTokenExpiry, refreshTokenExpiry: cfg.RefreshTokenExpiry}
+	i.keys.Store(cfg.Keys)
+	return i, nil
+}
+
+// SetKeySet replaces the keys used to sign and validate tokens
+func (i *Issuer) SetKeySet(ks *KeySet) {
+	i.keys.Store(ks)
+}
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+
+	// TokenVersion is the user's token version when the token was issued.
+	// Incrementing the user's version revokes all of their tokens at once.
+	TokenVersion int `json:"ver"`
+
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID int) (string, error) {
+// GenerateToken generates a JWT token for a user with the given role and token version
+func (i *Issuer) GenerateToken(userID int, role string, tokenVersion int) (string, error) {
+	// Generate a unique token ID so the token can be revoked individually
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
 	// Create the claims
 	claims := &Claims{
-		UserID: userID,
+		UserID:       userID,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpiry)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(i.tokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create the token, identifying the signing key in the header
+	key := i.keys.Load().active
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString(jwtSecret)
+	// Sign the token with the active key
+	tokenString, err := token.SignedString(key.signingKey)
 	if err != nil {
 		return "", fmt.Errorf("error signing token: %w", err)
 	}
@@ -49,15 +109,33 @@ func GenerateToken(userID int) (string, error) {
 	return tokenString, nil
 }
 
+// TokenExpiry returns how long a newly generated access token is valid for
+func (i *Issuer) TokenExpiry() time.Duration {
+	return i.tokenExpiry
+}
+
+// RefreshTokenExpiry returns how long a newly generated refresh token is valid for
+func (i *Issuer) RefreshTokenExpiry() time.Duration {
+	return i.refreshTokenExpiry
+}
+
 // ValidateToken validates a JWT token and returns the claims
-func ValidateToken(tokenString string) (*Claims, error) {
+func (i *Issuer) ValidateToken(tokenString string) (*Claims, error) {
 	// Parse the token
+	keys := i.keys.Load()
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Look up the signing key by its kid
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+
+		// Validate the signing method against the key's algorithm
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
+		return key.verificationKey, nil
 	})
 
 	if err != nil {
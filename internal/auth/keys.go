@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyConfig describes a single token signing key.
+//
+// HMAC keys (HS256, HS384, HS512) need a Secret or SecretFile. Asymmetric keys
+// (RS256, RS384, RS512, ES256, ES384, ES512, EdDSA) need a PEM PrivateKeyFile
+// to sign tokens, or only a PublicKeyFile to verify tokens signed elsewhere.
+type KeyConfig struct {
+	ID             string `json:"kid" yaml:"kid" toml:"kid"`
+	Algorithm      string `json:"alg" yaml:"alg" toml:"alg"`
+	Secret         string `json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"`
+	SecretFile     string `json:"secret_file,omitempty" yaml:"secret_file,omitempty" toml:"secret_file,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty" yaml:"private_key_file,omitempty" toml:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty" yaml:"public_key_file,omitempty" toml:"public_key_file,omitempty"`
+}
+
+// KeysConfig describes every key tokens may be signed with. New tokens are
+// signed with the Active key; all keys are accepted when validating tokens,
+// so a key can be rotated out by making another one active and removing it
+// once the tokens it signed have expired.
+type KeysConfig struct {
+	Active string      `json:"active" yaml:"active" toml:"active"`
+	Keys   []KeyConfig `json:"keys" yaml:"keys" toml:"keys"`
+}
+
+// Key is a token signing key identified by its kid
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signingKey      interface{} // nil for verification-only keys
+	verificationKey interface{}
+}
+
+// KeySet holds the keys used to sign and validate tokens
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeySet loads the keys described by the configuration
+func NewKeySet(cfg KeysConfig) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("signing key is missing a kid")
+		}
+		if _, exists := ks.keys[kc.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key %q", kc.ID)
+		}
+
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("error loading signing key %q: %w", kc.ID, err)
+		}
+		ks.keys[kc.ID] = key
+	}
+
+	active, ok := ks.keys[cfg.Active]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", cfg.Active)
+	}
+	if active.signingKey == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", cfg.Active)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// NewEphemeralKeySet generates a key set with a single random HMAC key.
+// Tokens signed with it become invalid when the process restarts.
+func NewEphemeralKeySet() (*KeySet, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	key := &Key{ID: "ephemeral", Method: jwt.SigningMethodHS256, signingKey: secret, verificationKey: secret}
+	return &KeySet{active: key, keys: map[string]*Key{key.ID: key}}, nil
+}
+
+// loadKey loads the key material described by a key configuration
+func loadKey(kc KeyConfig) (*Key, error) {
+	method := jwt.GetSigningMethod(kc.Algorithm)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+	key := &Key{ID: kc.ID, Method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := []byte(kc.Secret)
+		if kc.SecretFile != "" {
+			data, err := os.ReadFile(kc.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading secret file: %w", err)
+			}
+			secret = []byte(strings.TrimSpace(string(data)))
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HMAC secret must be at least 32 bytes long")
+		}
+		key.signingKey, key.verificationKey = secret, secret
+		return key, nil
+
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading private key file: %w", err)
+			}
+			private, public, err := parsePrivateKey(method, data)
+			if err != nil {
+				return nil, err
+			}
+			key.signingKey, key.verificationKey = private, public
+			return key, nil
+		}
+
+		if kc.PublicKeyFile != "" {
+			data, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading public key file: %w", err)
+			}
+			public, err := parsePublicKey(method, data)
+			if err != nil {
+				return nil, err
+			}
+			key.verificationKey = public
+			return key, nil
+		}
+
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+}
+
+// parsePrivateKey parses a PEM private key for the signing method, returning
+// it together with its public key
+func parsePrivateKey(method jwt.SigningMethod, data []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing RSA private key: %w", err)
+		}
+		return private, &private.PublicKey, nil
+	case *jwt.SigningMethodECDSA:
+		private, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing EC private key: %w", err)
+		}
+		if err := checkCurve(m, &private.PublicKey); err != nil {
+			return nil, nil, err
+		}
+		return private, &private.PublicKey, nil
+	default:
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing Ed25519 private key: %w", err)
+		}
+		return private, private.(ed25519.PrivateKey).Public(), nil
+	}
+}
+
+// parsePublicKey parses a PEM public key for the signing method
+func parsePublicKey(method jwt.SigningMethod, data []byte) (crypto.PublicKey, error) {
+	var (
+		public crypto.PublicKey
+		err    error
+	)
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	case *jwt.SigningMethodECDSA:
+		ecPublic, err := jwt.ParseECPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing public key: %w", err)
+		}
+		if err := checkCurve(m, ecPublic); err != nil {
+			return nil, err
+		}
+		return ecPublic, nil
+	default:
+		public, err = jwt.ParseEdPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	return public, nil
+}
+
+// checkCurve verifies that an ECDSA key is on the curve of its algorithm:
+// P-256 for ES256, P-384 for ES384 and P-521 for ES512
+func checkCurve(method *jwt.SigningMethodECDSA, public *ecdsa.PublicKey) error {
+	if bits := public.Curve.Params().BitSize; bits != method.CurveBits {
+		return fmt.Errorf("%s needs a P-%d key, got a P-%d key", method.Alg(), method.CurveBits, bits)
+	}
+	return nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA public key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP (Ed25519) public keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns the public keys of every asymmetric key of the issuer,
+// so other services can validate tokens. HMAC secrets are never published.
+func (i *Issuer) PublicJWKS() JWKS {
+	ks := i.keys.Load()
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+
+		switch public := key.verificationKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.KeyType = "EC"
+			jwk.Curve = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID })
+	return jwks
+}
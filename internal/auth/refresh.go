@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// GenerateRefreshToken generates an opaque random refresh token. Only its hash
+// (see HashRefreshToken) should be stored server-side.
+func GenerateRefreshToken() (string, error) {
+	return randomString(32)
+}
+
+// GenerateTokenFamily generates the identifier shared by a refresh token and
+// all the tokens it is rotated into
+func GenerateTokenFamily() (string, error) {
+	return randomString(16)
+}
+
+// HashRefreshToken returns the hash under which a refresh token is stored.
+// Refresh tokens are high-entropy random values, so an unsalted SHA-256 is sufficient.
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// randomString returns n random bytes encoded as unpadded base64url
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,496 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/password"
+)
+
+// Config is the application configuration.
+//
+// It is loaded by Load from, in increasing order of precedence: the defaults,
+// a JSON, YAML or TOML config file, environment variables and command-line flags.
+type Config struct {
+	Server   ServerConfig   `json:"server" yaml:"server" toml:"server"`
+	Database DatabaseConfig `json:"database" yaml:"database" toml:"database"`
+	Auth     AuthConfig     `json:"auth" yaml:"auth" toml:"auth"`
+	API      APIConfig      `json:"api" yaml:"api" toml:"api"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port            string   `json:"port" yaml:"port" toml:"port"`                                     // env PORT, flag -port
+	ReadTimeout     Duration `json:"read_timeout" yaml:"read_timeout" toml:"read_timeout"`             // env SERVER_READ_TIMEOUT, flag -read-timeout
+	WriteTimeout    Duration `json:"write_timeout" yaml:"write_timeout" toml:"write_timeout"`          // env SERVER_WRITE_TIMEOUT, flag -write-timeout
+	IdleTimeout     Duration `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"`             // env SERVER_IDLE_TIMEOUT, flag -idle-timeout
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" toml:"shutdown_timeout"` // env SERVER_SHUTDOWN_TIMEOUT, flag -shutdown-timeout
+}
+
+// DatabaseConfig configures the database
+type DatabaseConfig struct {
+	Driver string `json:"driver" yaml:"driver" toml:"driver"` // env DB_DRIVER, flag -db-driver (sqlite, postgres or memory)
+	Path   string `json:"path" yaml:"path" toml:"path"`       // env DB_PATH, flag -db
+	DSN    string `json:"dsn" yaml:"dsn" toml:"dsn"`          // env DB_DSN, flag -db-dsn
+
+	JournalMode  string   `json:"journal_mode" yaml:"journal_mode" toml:"journal_mode"`       // env DB_JOURNAL_MODE, flag -db-journal-mode (SQLite only)
+	BusyTimeout  Duration `json:"busy_timeout" yaml:"busy_timeout" toml:"busy_timeout"`       // env DB_BUSY_TIMEOUT, flag -db-busy-timeout (SQLite only)
+	MaxOpenConns int      `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns"` // env DB_MAX_OPEN_CONNS, flag -db-max-open-conns
+	MaxIdleConns int      `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"` // env DB_MAX_IDLE_CONNS, flag -db-max-idle-conns
+}
+
+// AuthConfig configures authentication.
+//
+// Signing keys are taken from the first of Keys, KeysFile, Secret or
+// SecretFile that is set; see auth.KeysConfig for the format of Keys and KeysFile.
+type AuthConfig struct {
+	AccessTokenExpiry  Duration         `json:"access_token_expiry" yaml:"access_token_expiry" toml:"access_token_expiry"`    // env ACCESS_TOKEN_EXPIRY, flag -access-token-expiry
+	RefreshTokenExpiry Duration         `json:"refresh_token_expiry" yaml:"refresh_token_expiry" toml:"refresh_token_expiry"` // env REFRESH_TOKEN_EXPIRY, flag -refresh-token-expiry
+	PasswordHasher     string           `json:"password_hasher" yaml:"password_hasher" toml:"password_hasher"`                // env PASSWORD_HASHER, flag -password-hasher
+	Keys               *auth.KeysConfig `json:"keys,omitempty" yaml:"keys,omitempty" toml:"keys,omitempty"`
+	KeysFile           string           `json:"keys_file,omitempty" yaml:"keys_file,omitempty" toml:"keys_file,omitempty"`       // env JWT_KEYS_FILE
+	Secret             string           `json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"`                // env JWT_SECRET
+	SecretFile         string           `json:"secret_file,omitempty" yaml:"secret_file,omitempty" toml:"secret_file,omitempty"` // env JWT_SECRET_FILE
+}
+
+// APIConfig configures the behavior of the API endpoints
+type APIConfig struct {
+	DefaultPageSize int    `json:"default_page_size" yaml:"default_page_size" toml:"default_page_size"`                   // env DEFAULT_PAGE_SIZE, flag -default-page-size
+	MaxPageSize     int    `json:"max_page_size" yaml:"max_page_size" toml:"max_page_size"`                               // env MAX_PAGE_SIZE, flag -max-page-size
+	CursorSecret    string `json:"cursor_secret,omitempty" yaml:"cursor_secret,omitempty" toml:"cursor_secret,omitempty"` // env CURSOR_SECRET
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(15 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:       "sqlite",
+			Path:         "./product_catalog.db",
+			JournalMode:  "wal",
+			BusyTimeout:  Duration(5 * time.Second),
+			MaxOpenConns: 10,
+			MaxIdleConns: 10,
+		},
+		Auth: AuthConfig{
+			AccessTokenExpiry:  Duration(15 * time.Minute),
+			RefreshTokenExpiry: Duration(30 * 24 * time.Hour),
+			PasswordHasher:     "argon2id",
+		},
+		API: APIConfig{
+			DefaultPageSize: 10,
+			MaxPageSize:     100,
+		},
+	}
+}
+
+// Load registers the configuration flags on fs, parses args and returns the
+// validated configuration. The config file is named by the -config flag or
+// the CONFIG_FILE environment variable; its format is chosen by its extension
+// (.json, .yaml, .yml or .toml).
+//
+// Callers may register their own flags on fs before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	// Register the flags; only flags set explicitly override other sources
+	configFile := fs.String("config", "", "Path to a JSON, YAML or TOML config file")
+	port := fs.String("port", cfg.Server.Port, "Port to listen on")
+	readTimeout := fs.Duration("read-timeout", time.Duration(cfg.Server.ReadTimeout), "Maximum duration for reading a request")
+	writeTimeout := fs.Duration("write-timeout", time.Duration(cfg.Server.WriteTimeout), "Maximum duration for writing a response")
+	idleTimeout := fs.Duration("idle-timeout", time.Duration(cfg.Server.IdleTimeout), "Maximum time to keep idle connections open")
+	shutdownTimeout := fs.Duration("shutdown-timeout", time.Duration(cfg.Server.ShutdownTimeout), "Maximum time to drain connections on shutdown")
+	dbDriver := fs.String("db-driver", cfg.Database.Driver, "Database backend (sqlite, postgres or memory)")
+	dbPath := fs.String("db", cfg.Database.Path, "Path to SQLite database file")
+	dbDSN := fs.String("db-dsn", cfg.Database.DSN, "Postgres connection string")
+	dbJournalMode := fs.String("db-journal-mode", cfg.Database.JournalMode, "SQLite journal mode (wal, delete, truncate, persist, memory or off)")
+	dbBusyTimeout := fs.Duration("db-busy-timeout", time.Duration(cfg.Database.BusyTimeout), "How long SQLite waits for locks held by other connections")
+	dbMaxOpenConns := fs.Int("db-max-open-conns", cfg.Database.MaxOpenConns, "Maximum number of open database connections")
+	dbMaxIdleConns := fs.Int("db-max-idle-conns", cfg.Database.MaxIdleConns, "Maximum number of idle database connections")
+	accessTokenExpiry := fs.Duration("access-token-expiry", time.Duration(cfg.Auth.AccessTokenExpiry), "Lifetime of access tokens")
+	refreshTokenExpiry := fs.Duration("refresh-token-expiry", time.Duration(cfg.Auth.RefreshTokenExpiry), "Lifetime of refresh tokens")
+	passwordHasher := fs.String("password-hasher", cfg.Auth.PasswordHasher, "Algorithm for new password hashes (argon2id, bcrypt or scrypt)")
+	defaultPageSize := fs.Int("default-page-size", cfg.API.DefaultPageSize, "Page size when a request has no limit")
+	maxPageSize := fs.Int("max-page-size", cfg.API.MaxPageSize, "Largest page size a request may ask for")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// Config file
+	path := os.Getenv("CONFIG_FILE")
+	if set["config"] {
+		path = *configFile
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment variables
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Command-line flags
+	if set["port"] {
+		cfg.Server.Port = *port
+	}
+	if set["read-timeout"] {
+		cfg.Server.ReadTimeout = Duration(*readTimeout)
+	}
+	if set["write-timeout"] {
+		cfg.Server.WriteTimeout = Duration(*writeTimeout)
+	}
+	if set["idle-timeout"] {
+		cfg.Server.IdleTimeout = Duration(*idleTimeout)
+	}
+	if set["shutdown-timeout"] {
+		cfg.Server.ShutdownTimeout = Duration(*shutdownTimeout)
+	}
+	if set["db-driver"] {
+		cfg.Database.Driver = *dbDriver
+	}
+	if set["db"] {
+		cfg.Database.Path = *dbPath
+	}
+	if set["db-dsn"] {
+		cfg.Database.DSN = *dbDSN
+	}
+	if set["db-journal-mode"] {
+		cfg.Database.JournalMode = *dbJournalMode
+	}
+	if set["db-busy-timeout"] {
+		cfg.Database.BusyTimeout = Duration(*dbBusyTimeout)
+	}
+	if set["db-max-open-conns"] {
+		cfg.Database.MaxOpenConns = *dbMaxOpenConns
+	}
+	if set["db-max-idle-conns"] {
+		cfg.Database.MaxIdleConns = *dbMaxIdleConns
+	}
+	if set["access-token-expiry"] {
+		cfg.Auth.AccessTokenExpiry = Duration(*accessTokenExpiry)
+	}
+	if set["refresh-token-expiry"] {
+		cfg.Auth.RefreshTokenExpiry = Duration(*refreshTokenExpiry)
+	}
+	if set["password-hasher"] {
+		cfg.Auth.PasswordHasher = *passwordHasher
+	}
+	if set["default-page-size"] {
+		cfg.API.DefaultPageSize = *defaultPageSize
+	}
+	if set["max-page-size"] {
+		cfg.API.MaxPageSize = *maxPageSize
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides the configuration with the values of a config file
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	case ".toml":
+		err = decodeTOML(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// decodeTOML decodes a TOML document into v, rejecting unknown keys like the
+// JSON and YAML decoders do
+func decodeTOML(data []byte, v interface{}) error {
+	metadata, err := toml.NewDecoder(bytes.NewReader(data)).Decode(v)
+	if err != nil {
+		return err
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown key %s", undecoded[0])
+	}
+	return nil
+}
+
+// loadEnv overrides the configuration with the environment variables that are set
+func (c *Config) loadEnv() error {
+	stringVars := map[string]*string{
+		"PORT":            &c.Server.Port,
+		"DB_DRIVER":       &c.Database.Driver,
+		"DB_PATH":         &c.Database.Path,
+		"DB_DSN":          &c.Database.DSN,
+		"DB_JOURNAL_MODE": &c.Database.JournalMode,
+		"PASSWORD_HASHER": &c.Auth.PasswordHasher,
+		"JWT_KEYS_FILE":   &c.Auth.KeysFile,
+		"JWT_SECRET":      &c.Auth.Secret,
+		"JWT_SECRET_FILE": &c.Auth.SecretFile,
+		"CURSOR_SECRET":   &c.API.CursorSecret,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	durationVars := map[string]*Duration{
+		"SERVER_READ_TIMEOUT":     &c.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":    &c.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":     &c.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT": &c.Server.ShutdownTimeout,
+		"DB_BUSY_TIMEOUT":         &c.Database.BusyTimeout,
+		"ACCESS_TOKEN_EXPIRY":     &c.Auth.AccessTokenExpiry,
+		"REFRESH_TOKEN_EXPIRY":    &c.Auth.RefreshTokenExpiry,
+	}
+	for name, field := range durationVars {
+		if value, ok := os.LookupEnv(name); ok {
+			if err := field.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	intVars := map[string]*int{
+		"DB_MAX_OPEN_CONNS": &c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.Database.MaxIdleConns,
+		"DEFAULT_PAGE_SIZE": &c.API.DefaultPageSize,
+		"MAX_PAGE_SIZE":     &c.API.MaxPageSize,
+	}
+	for name, field := range intVars {
+		if value, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field = n
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration is complete and consistent
+func (c *Config) Validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("server.port must be a number between 1 and 65535, got %q", c.Server.Port))
+	}
+	serverTimeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+	}
+	for _, timeout := range serverTimeouts {
+		if timeout.value <= 0 {
+			problems = append(problems, timeout.name+" must be positive")
+		}
+	}
+	switch c.Database.Driver {
+	case "sqlite":
+		if c.Database.Path == "" {
+			problems = append(problems, "database.path is required")
+		}
+		// The journal mode and busy timeout only apply to SQLite
+		if !db.ValidJournalMode(c.Database.JournalMode) {
+			problems = append(problems, fmt.Sprintf("database.journal_mode must be wal, delete, truncate, persist, memory or off, got %q", c.Database.JournalMode))
+		}
+		if c.Database.BusyTimeout <= 0 {
+			problems = append(problems, "database.busy_timeout must be positive")
+		}
+	case "postgres":
+		if c.Database.DSN == "" {
+			problems = append(problems, "database.dsn is required for the postgres driver")
+		}
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver must be sqlite, postgres or memory, got %q", c.Database.Driver))
+	}
+	if c.Database.MaxOpenConns < 1 {
+		problems = append(problems, "database.max_open_conns must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 1 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, "database.max_idle_conns must be between 1 and database.max_open_conns")
+	}
+	if c.Auth.AccessTokenExpiry <= 0 {
+		problems = append(problems, "auth.access_token_expiry must be positive")
+	}
+	if c.Auth.RefreshTokenExpiry <= c.Auth.AccessTokenExpiry {
+		problems = append(problems, "auth.refresh_token_expiry must be longer than auth.access_token_expiry")
+	}
+	if _, err := password.New(c.Auth.PasswordHasher); err != nil {
+		problems = append(problems, fmt.Sprintf("auth.password_hasher must be argon2id, bcrypt or scrypt, got %q", c.Auth.PasswordHasher))
+	}
+	if c.Auth.Secret != "" && c.Auth.SecretFile != "" {
+		problems = append(problems, "only one of auth.secret and auth.secret_file may be set")
+	}
+	if c.API.DefaultPageSize < 1 {
+		problems = append(problems, "api.default_page_size must be at least 1")
+	}
+	if c.API.MaxPageSize < 1 {
+		problems = append(problems, "api.max_page_size must be at least 1")
+	} else if c.API.DefaultPageSize > c.API.MaxPageSize {
+		problems = append(problems, "api.default_page_size must not exceed api.max_page_size")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Source returns what the database driver opens: the DSN for postgres, or
+// the absolute database path otherwise
+func (c DatabaseConfig) Source() (string, error) {
+	if c.Driver == "postgres" {
+		return c.DSN, nil
+	}
+	absPath, err := filepath.Abs(c.Path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving database path: %w", err)
+	}
+	return absPath, nil
+}
+
+// Options returns the connection settings of the database
+func (c DatabaseConfig) Options() db.Options {
+	return db.Options{
+		JournalMode:  c.JournalMode,
+		BusyTimeout:  time.Duration(c.BusyTimeout),
+		MaxOpenConns: c.MaxOpenConns,
+		MaxIdleConns: c.MaxIdleConns,
+	}
+}
+
+// StoreOptions returns the options of the stores: the connection settings of
+// the database and the password hasher
+func (c *Config) StoreOptions() (db.Options, error) {
+	hasher, err := password.New(c.Auth.PasswordHasher)
+	if err != nil {
+		return db.Options{}, fmt.Errorf("error configuring password hashing: %w", err)
+	}
+
+	options := c.Database.Options()
+	options.Hasher = hasher
+	return options, nil
+}
+
+// KeysConfigured reports whether token signing keys are configured, rather
+// than generated when the server starts
+func (c AuthConfig) KeysConfigured() bool {
+	return c.Keys != nil || c.KeysFile != "" || c.Secret != "" || c.SecretFile != ""
+}
+
+// KeySet loads the token signing keys. If no key is configured, an ephemeral
+// key is generated and a warning is logged.
+func (c AuthConfig) KeySet() (*auth.KeySet, error) {
+	switch {
+	case c.Keys != nil:
+		return auth.NewKeySet(*c.Keys)
+
+	case c.KeysFile != "":
+		var keys auth.KeysConfig
+		data, err := os.ReadFile(c.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading keys file: %w", err)
+		}
+		switch strings.ToLower(filepath.Ext(c.KeysFile)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &keys)
+		case ".toml":
+			err = decodeTOML(data, &keys)
+		default:
+			err = json.Unmarshal(data, &keys)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing keys file: %w", err)
+		}
+		return auth.NewKeySet(keys)
+
+	case c.Secret != "" || c.SecretFile != "":
+		return auth.NewKeySet(auth.KeysConfig{
+			Active: "default",
+			Keys:   []auth.KeyConfig{{ID: "default", Algorithm: "HS256", Secret: c.Secret, SecretFile: c.SecretFile}},
+		})
+	}
+
+	log.Println("Warning: no JWT signing key configured, using an ephemeral key; tokens will not survive a restart")
+	return auth.NewEphemeralKeySet()
+}
+
+// CursorKey returns the key signing pagination cursors. If no secret is
+// configured, a warning is logged and nil is returned so that the handlers
+// generate an ephemeral key.
+func (c APIConfig) CursorKey() []byte {
+	if c.CursorSecret == "" {
+		log.Println("Warning: no cursor secret configured, using an ephemeral key; pagination cursors will not survive a restart")
+		return nil
+	}
+	return []byte(c.CursorSecret)
+}
+
+// Duration is a time.Duration written as a string such as "15m" or "720h" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
@@ -0,0 +1,293 @@
+package tests
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/config"
+)
+
+// load loads the configuration from a fresh flag set
+func load(args ...string) (*config.Config, error) {
+	return config.Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+// writeConfigFile writes a config file into a temporary directory and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestDefaults(t *testing.T) {
+	cfg, err := load()
+	if err != nil {
+		t.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if cfg.Server.Port != "8080" || cfg.Database.Path != "./product_catalog.db" {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+	if time.Duration(cfg.Auth.AccessTokenExpiry) != 15*time.Minute {
+		t.Errorf("Expected access token expiry of 15m, got %v", time.Duration(cfg.Auth.AccessTokenExpiry))
+	}
+	server := cfg.Server
+	if time.Duration(server.ReadTimeout) != 15*time.Second || time.Duration(server.WriteTimeout) != 15*time.Second ||
+		time.Duration(server.IdleTimeout) != time.Minute || time.Duration(server.ShutdownTimeout) != 30*time.Second {
+		t.Errorf("Expected server timeouts of 15s, 15s, 1m and 30s, got %+v", server)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "9000"
+  read_timeout: 20s
+  shutdown_timeout: 45s
+database:
+  path: /data/from-file.db
+auth:
+  access_token_expiry: 5m
+api:
+  default_page_size: 25
+`)
+
+	t.Run("File overrides defaults", func(t *testing.T) {
+		cfg, err := load("-config", path)
+		if err != nil {
+			t.Fatalf("Error loading configuration: %v", err)
+		}
+		if cfg.Server.Port != "9000" || cfg.Database.Path != "/data/from-file.db" || cfg.API.DefaultPageSize != 25 {
+			t.Errorf("Expected values from the file, got %+v", cfg)
+		}
+		if time.Duration(cfg.Auth.AccessTokenExpiry) != 5*time.Minute {
+			t.Errorf("Expected access token expiry of 5m, got %v", time.Duration(cfg.Auth.AccessTokenExpiry))
+		}
+		if time.Duration(cfg.Server.ReadTimeout) != 20*time.Second || time.Duration(cfg.Server.ShutdownTimeout) != 45*time.Second {
+			t.Errorf("Expected read and shutdown timeouts of 20s and 45s, got %+v", cfg.Server)
+		}
+	})
+
+	t.Run("Environment overrides file", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", path)
+		t.Setenv("DB_PATH", "/data/from-env.db")
+		t.Setenv("ACCESS_TOKEN_EXPIRY", "10m")
+		t.Setenv("CURSOR_SECRET", "cursor-secret")
+		t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "10s")
+		t.Setenv("SERVER_IDLE_TIMEOUT", "90s")
+
+		cfg, err := load()
+		if err != nil {
+			t.Fatalf("Error loading configuration: %v", err)
+		}
+		if cfg.Server.Port != "9000" || cfg.Database.Path != "/data/from-env.db" || cfg.API.CursorSecret != "cursor-secret" {
+			t.Errorf("Expected the port from the file and the path from the environment, got %+v", cfg)
+		}
+		if time.Duration(cfg.Auth.AccessTokenExpiry) != 10*time.Minute {
+			t.Errorf("Expected access token expiry of 10m, got %v", time.Duration(cfg.Auth.AccessTokenExpiry))
+		}
+		if time.Duration(cfg.Server.ReadTimeout) != 20*time.Second || time.Duration(cfg.Server.ShutdownTimeout) != 10*time.Second ||
+			time.Duration(cfg.Server.IdleTimeout) != 90*time.Second {
+			t.Errorf("Expected the read timeout from the file and the idle and shutdown timeouts from the environment, got %+v", cfg.Server)
+		}
+	})
+
+	t.Run("Flags override environment", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", path)
+		t.Setenv("DB_PATH", "/data/from-env.db")
+		t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "10s")
+
+		cfg, err := load("-db", "/data/from-flag.db", "-port", "9100", "-shutdown-timeout", "5s", "-write-timeout", "1m")
+		if err != nil {
+			t.Fatalf("Error loading configuration: %v", err)
+		}
+		if cfg.Server.Port != "9100" || cfg.Database.Path != "/data/from-flag.db" {
+			t.Errorf("Expected values from the flags, got %+v", cfg)
+		}
+		if time.Duration(cfg.Server.ShutdownTimeout) != 5*time.Second || time.Duration(cfg.Server.WriteTimeout) != time.Minute {
+			t.Errorf("Expected shutdown and write timeouts of 5s and 1m from the flags, got %+v", cfg.Server)
+		}
+	})
+}
+
+func TestJSONConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"auth": {
+			"refresh_token_expiry": "48h",
+			"password_hasher": "bcrypt",
+			"keys": {"active": "k1", "keys": [{"kid": "k1", "alg": "HS256", "secret": "0123456789abcdef0123456789abcdef"}]}
+		}
+	}`)
+
+	cfg, err := load("-config", path)
+	if err != nil {
+		t.Fatalf("Error loading configuration: %v", err)
+	}
+	if time.Duration(cfg.Auth.RefreshTokenExpiry) != 48*time.Hour || cfg.Auth.PasswordHasher != "bcrypt" {
+		t.Errorf("Unexpected auth configuration: %+v", cfg.Auth)
+	}
+	if _, err := cfg.Auth.KeySet(); err != nil {
+		t.Errorf("Error loading key set: %v", err)
+	}
+}
+
+func TestTOMLConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+[server]
+port = "9200"
+read_timeout = "20s"
+
+[database]
+path = "/data/from-toml.db"
+journal_mode = "delete"
+
+[auth]
+access_token_expiry = "5m"
+
+[auth.keys]
+active = "k1"
+
+[[auth.keys.keys]]
+kid = "k1"
+alg = "HS256"
+secret = "0123456789abcdef0123456789abcdef"
+
+[api]
+default_page_size = 25
+`)
+
+	cfg, err := load("-config", path)
+	if err != nil {
+		t.Fatalf("Error loading configuration: %v", err)
+	}
+	if cfg.Server.Port != "9200" || cfg.Database.Path != "/data/from-toml.db" || cfg.Database.JournalMode != "delete" || cfg.API.DefaultPageSize != 25 {
+		t.Errorf("Expected values from the file, got %+v", cfg)
+	}
+	if time.Duration(cfg.Server.ReadTimeout) != 20*time.Second || time.Duration(cfg.Auth.AccessTokenExpiry) != 5*time.Minute {
+		t.Errorf("Expected read timeout of 20s and access token expiry of 5m, got %+v", cfg)
+	}
+	if _, err := cfg.Auth.KeySet(); err != nil {
+		t.Errorf("Error loading key set: %v", err)
+	}
+
+	t.Run("Unknown key", func(t *testing.T) {
+		path := writeConfigFile(t, "config.toml", "[server]\nhostname = \"example.com\"\n")
+		if _, err := load("-config", path); err == nil || !strings.Contains(err.Error(), "unknown key server.hostname") {
+			t.Errorf("Expected unknown key error, got %v", err)
+		}
+	})
+}
+
+func TestPostgresIgnoresSQLiteSettings(t *testing.T) {
+	t.Setenv("DB_JOURNAL_MODE", "fast")
+	t.Setenv("DB_BUSY_TIMEOUT", "0s")
+
+	if _, err := load("-db-driver", "postgres", "-db-dsn", "postgres://localhost/catalog"); err != nil {
+		t.Errorf("Expected SQLite settings to be ignored for postgres, got %v", err)
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		env           map[string]string
+		file          string
+		expectedError string
+	}{
+		{
+			name:          "Invalid port",
+			args:          []string{"-port", "http"},
+			expectedError: "server.port must be a number",
+		},
+		{
+			name:          "Zero shutdown timeout",
+			args:          []string{"-shutdown-timeout", "0s"},
+			expectedError: "server.shutdown_timeout must be positive",
+		},
+		{
+			name:          "Negative read timeout in environment",
+			env:           map[string]string{"SERVER_READ_TIMEOUT": "-1s"},
+			expectedError: "server.read_timeout must be positive",
+		},
+		{
+			name:          "Zero idle timeout in file",
+			file:          "server:\n  idle_timeout: 0s\n",
+			expectedError: "server.idle_timeout must be positive",
+		},
+		{
+			name:          "Refresh expiry shorter than access expiry",
+			args:          []string{"-access-token-expiry", "2h", "-refresh-token-expiry", "1h"},
+			expectedError: "auth.refresh_token_expiry must be longer",
+		},
+		{
+			name:          "Unknown password hasher",
+			env:           map[string]string{"PASSWORD_HASHER": "md5"},
+			expectedError: "auth.password_hasher must be",
+		},
+		{
+			name:          "Unknown database driver",
+			env:           map[string]string{"DB_DRIVER": "mysql"},
+			expectedError: "database.driver must be sqlite, postgres or memory",
+		},
+		{
+			name:          "Unknown journal mode",
+			env:           map[string]string{"DB_JOURNAL_MODE": "fast"},
+			expectedError: "database.journal_mode must be",
+		},
+		{
+			name:          "More idle than open connections",
+			args:          []string{"-db-max-open-conns", "2", "-db-max-idle-conns", "5"},
+			expectedError: "database.max_idle_conns must be between 1 and database.max_open_conns",
+		},
+		{
+			name:          "Default page size above the maximum",
+			env:           map[string]string{"MAX_PAGE_SIZE": "20"},
+			args:          []string{"-default-page-size", "50"},
+			expectedError: "api.default_page_size must not exceed api.max_page_size",
+		},
+		{
+			name:          "Postgres without DSN",
+			args:          []string{"-db-driver", "postgres"},
+			expectedError: "database.dsn is required",
+		},
+		{
+			name:          "Invalid duration in environment",
+			env:           map[string]string{"ACCESS_TOKEN_EXPIRY": "soon"},
+			expectedError: "invalid ACCESS_TOKEN_EXPIRY",
+		},
+		{
+			name:          "Unknown field in file",
+			file:          "server:\n  hostname: example.com\n",
+			expectedError: "field hostname not found",
+		},
+		{
+			name:          "Several problems are reported together",
+			args:          []string{"-db", "", "-default-page-size", "0"},
+			expectedError: "database.path is required; api.default_page_size must be at least 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+			args := tc.args
+			if tc.file != "" {
+				args = append(args, "-config", writeConfigFile(t, "config.yml", tc.file))
+			}
+
+			_, err := load(args...)
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// Errors of category operations
+var (
+	// ErrCategoryNotFound is returned when no category exists with the given slug
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists is returned when creating a category whose slug is taken
+	ErrCategoryExists = errors.New("category already exists")
+	// ErrParentNotFound is returned when the parent of a category doesn't exist
+	ErrParentNotFound = errors.New("parent category not found")
+	// ErrCategoryCycle is returned when moving a category under itself or one
+	// of its descendants
+	ErrCategoryCycle = errors.New("category cannot be its own ancestor")
+	// ErrCategoryInUse is returned when deleting a category that still has
+	// products or subcategories
+	ErrCategoryInUse = errors.New("category has products or subcategories")
+)
+
+// categoryQuery selects the categories c read into a models.Category by
+// categoryFields
+const categoryQuery = `SELECT c.id, c.slug, c.name, COALESCE(parent.slug, ''),
+	(SELECT COUNT(*) FROM products p WHERE p.category = c.slug)
+	FROM categories c LEFT JOIN categories parent ON parent.id = c.parent_id`
+
+// categoryFields returns the destinations to scan categoryQuery into
+func categoryFields(category *models.Category) []interface{} {
+	return []interface{}{&category.ID, &category.Slug, &category.Name, &category.Parent, &category.ProductCount}
+}
+
+// ensureCategoryQuery creates the category of a product, given its slug
+// three times, as a top-level category named after its slug unless it
+// exists. Existing categories are looked up first so that they don't use up
+// IDs, as a conflicting insert would on Postgres.
+const ensureCategoryQuery = `INSERT INTO categories (slug, name)
+	SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM categories WHERE slug = ?)
+	ON CONFLICT (slug) DO NOTHING`
+
+// subcategoriesQuery selects the slugs of the categories listed by the %s
+// placeholders and of all their descendants
+const subcategoriesQuery = `WITH RECURSIVE tree (id, slug) AS (
+	SELECT id, slug FROM categories WHERE slug IN (%s)
+	UNION SELECT c.id, c.slug FROM categories c JOIN tree t ON c.parent_id = t.id
+) SELECT slug FROM tree`
+
+// isDescendantQuery reports whether the category with the second ID is the
+// one with the first ID or one of its descendants
+const isDescendantQuery = `WITH RECURSIVE tree (id) AS (
+	SELECT CAST(? AS INTEGER)
+	UNION SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+) SELECT EXISTS (SELECT 1 FROM tree WHERE id = ?)`
+
+// deleteUnusedCategoryQuery deletes a category by slug, unless it has
+// products or subcategories
+const deleteUnusedCategoryQuery = `DELETE FROM categories WHERE slug = ?
+	AND NOT EXISTS (SELECT 1 FROM products p WHERE p.category = categories.slug)
+	AND NOT EXISTS (SELECT 1 FROM categories child WHERE child.parent_id = categories.id)`
+
+// scanCategories reads the categories selected by categoryQuery
+func scanCategories(rows *sql.Rows) ([]models.Category, error) {
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(categoryFields(&category)...); err != nil {
+			return nil, fmt.Errorf("error scanning category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %w", err)
+	}
+	return categories, nil
+}
+
+// findParent looks up the ID of a parent category by slug with query, which
+// takes the slug. Top-level categories have a null parent.
+func findParent(tx *sql.Tx, query, slug string) (sql.NullInt64, error) {
+	if slug == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	var id sql.NullInt64
+	err := tx.QueryRow(query, slug).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, ErrParentNotFound
+	}
+	if err != nil {
+		return id, fmt.Errorf("error querying parent category: %w", err)
+	}
+	return id, nil
+}
+
+// GetCategories retrieves every category along with its product count, in ID
+// order
+func (s *SQLiteStore) GetCategories() ([]models.Category, error) {
+	rows, err := s.db.Query(categoryQuery + " ORDER BY c.id")
+	if err != nil {
+		return nil, fmt.Errorf("error querying categories: %w", err)
+	}
+	return scanCategories(rows)
+}
+
+// GetCategory retrieves a category by slug
+func (s *SQLiteStore) GetCategory(slug string) (*models.Category, error) {
+	var category models.Category
+	err := s.db.QueryRow(categoryQuery+" WHERE c.slug = ?", slug).Scan(categoryFields(&category)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("error querying category: %w", err)
+	}
+	return &category, nil
+}
+
+// CreateCategory inserts a new category under its parent, if any, and
+// returns it with its assigned ID
+func (s *SQLiteStore) CreateCategory(category models.Category) (*models.Category, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	parentID, err := findParent(tx, "SELECT id FROM categories WHERE slug = ?", category.Parent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for the slug first, so that it doesn't use up an ID
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE slug = ?)", category.Slug).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error querying category: %w", err)
+	}
+	if exists {
+		return nil, ErrCategoryExists
+	}
+
+	result, err := tx.Exec("INSERT INTO categories (slug, name, parent_id) VALUES (?, ?, ?)", category.Slug, category.Name, parentID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrCategoryExists
+		}
+		if isForeignKeyViolation(err) {
+			return nil, ErrParentNotFound
+		}
+		return nil, fmt.Errorf("error creating category: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	category.ID = int(id)
+	category.ProductCount = 0
+	return &category, nil
+}
+
+// UpdateCategory renames the category with the given slug and moves it under
+// the given parent, or to the top level
+func (s *SQLiteStore) UpdateCategory(category models.Category) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM categories WHERE slug = ?", category.Slug).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error querying category: %w", err)
+	}
+
+	parentID, err := findParent(tx, "SELECT id FROM categories WHERE slug = ?", category.Parent)
+	if err != nil {
+		return err
+	}
+	if parentID.Valid {
+		var cycle bool
+		if err := tx.QueryRow(isDescendantQuery, id, parentID).Scan(&cycle); err != nil {
+			return fmt.Errorf("error checking category ancestors: %w", err)
+		}
+		if cycle {
+			return ErrCategoryCycle
+		}
+	}
+
+	if _, err = tx.Exec("UPDATE categories SET name = ?, parent_id = ? WHERE id = ?", category.Name, parentID, id); err != nil {
+		return fmt.Errorf("error updating category: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category that has neither products nor
+// subcategories
+func (s *SQLiteStore) DeleteCategory(slug string) error {
+	result, err := s.db.Exec(deleteUnusedCategoryQuery, slug)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrCategoryInUse
+		}
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+	if err := requireAffected(result, ErrCategoryInUse); !errors.Is(err, ErrCategoryInUse) {
+		return err
+	}
+
+	// Tell a missing category from one in use
+	if _, err := s.GetCategory(slug); err != nil {
+		return err
+	}
+	return ErrCategoryInUse
+}
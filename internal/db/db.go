@@ -2,18 +2,27 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/najwa/product-catalog-api/internal/password"
 )
 
-// DB is the database connection
-var DB *sql.DB
+// SQLiteStore stores the catalog in a SQLite database
+type SQLiteStore struct {
+	db        *sql.DB
+	hasher    password.Hasher
+	dummyHash func() string
+}
 
-// Initialize initializes the database connection and creates tables if they don't exist
-func Initialize(dbPath string) error {
+// OpenSQLite opens the SQLite database at dbPath, creating the file if it
+// doesn't exist and applying any pending migrations
+func OpenSQLite(dbPath string, options Options) (*SQLiteStore, error) {
 	var err error
 
 	// Check if the database file exists
@@ -22,82 +31,51 @@ func Initialize(dbPath string) error {
 		// Create the database file
 		file, err := os.Create(dbPath)
 		if err != nil {
-			return fmt.Errorf("error creating database file: %w", err)
+			return nil, fmt.Errorf("error creating database file: %w", err)
 		}
 		file.Close()
 	}
 
 	// Open the database
-	DB, err = sql.Open("sqlite", dbPath)
+	options = options.withDefaults()
+	conn, err := sql.Open("sqlite", options.sqliteDSN(dbPath))
 	if err != nil {
-		return fmt.Errorf("error opening database: %w", err)
+		return nil, fmt.Errorf("error opening database: %w", err)
 	}
+	options.configurePool(conn)
+	s := &SQLiteStore{db: conn, hasher: options.Hasher, dummyHash: dummyPasswordHash(options.Hasher)}
 
 	// Test the connection
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("error connecting to database: %w", err)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	// Create tables if they don't exist
-	if err = createTables(); err != nil {
-		return fmt.Errorf("error creating tables: %w", err)
+	// Bring the schema up to date
+	if err = migrate(conn, "sqlite"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error migrating database: %w", err)
 	}
 
 	log.Println("Database initialized successfully")
-	return nil
+	return s, nil
 }
 
-// createTables creates the necessary tables if they don't exist
-func createTables() error {
-	// Create users table
-	_, err := DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("error creating users table: %w", err)
-	}
-
-	// Create products table
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS products (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			price REAL NOT NULL,
-			category TEXT NOT NULL,
-			image TEXT NOT NULL
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("error creating products table: %w", err)
-	}
-
-	// Create favorites table
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS favorites (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			product_id INTEGER NOT NULL,
-			notes TEXT,
-			FOREIGN KEY (user_id) REFERENCES users (id),
-			FOREIGN KEY (product_id) REFERENCES products (id),
-			UNIQUE (user_id, product_id)
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("error creating favorites table: %w", err)
-	}
+// isForeignKeyViolation reports whether err is SQLite rejecting a reference to
+// a missing row
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
 
-	return nil
+// isUniqueViolation reports whether err is SQLite rejecting a duplicate value
+// of a unique column
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
 }
 
 // Close closes the database connection
-func Close() error {
-	if DB != nil {
-		return DB.Close()
-	}
-	return nil
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
 }
@@ -2,25 +2,29 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
+// ErrFavoriteNotFound is returned when a user has not favorited the given product
+var ErrFavoriteNotFound = errors.New("favorite not found")
+
 // AddFavorite adds a product to a user's favorites
-func AddFavorite(userID, productID int, notes string) error {
+func (s *SQLiteStore) AddFavorite(userID, productID int, notes string) error {
 	// Check if the product exists
-	_, err := GetProductByID(productID)
+	_, err := s.GetProductByID(productID)
 	if err != nil {
-		return fmt.Errorf("product not found: %w", err)
+		return err
 	}
 
 	// Check if the favorite already exists
 	var id int
-	err = DB.QueryRow("SELECT id FROM favorites WHERE user_id = ? AND product_id = ?", userID, productID).Scan(&id)
+	err = s.db.QueryRow("SELECT id FROM favorites WHERE user_id = ? AND product_id = ?", userID, productID).Scan(&id)
 	if err == nil {
 		// Favorite already exists, update the notes
-		_, err = DB.Exec("UPDATE favorites SET notes = ? WHERE id = ?", notes, id)
+		_, err = s.db.Exec("UPDATE favorites SET notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", notes, id)
 		if err != nil {
 			return fmt.Errorf("error updating favorite: %w", err)
 		}
@@ -30,58 +34,73 @@ func AddFavorite(userID, productID int, notes string) error {
 	}
 
 	// Add the favorite
-	_, err = DB.Exec("INSERT INTO favorites (user_id, product_id, notes) VALUES (?, ?, ?)", userID, productID, notes)
+	_, err = s.db.Exec("INSERT INTO favorites (user_id, product_id, notes) VALUES (?, ?, ?)", userID, productID, notes)
 	if err != nil {
+		// The product exists, so the user must be missing
+		if isForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
 		return fmt.Errorf("error adding favorite: %w", err)
 	}
 
 	return nil
 }
 
-// GetFavorites retrieves a user's favorite products
-func GetFavorites(userID int) ([]models.Product, error) {
+// GetFavorites retrieves a page of a user's favorite products, most recent first,
+// along with the total number of favorites
+func (s *SQLiteStore) GetFavorites(userID, page, limit int) ([]models.FavoriteProduct, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	// Count all of the user's favorites
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM favorites WHERE user_id = ?", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting favorites: %w", err)
+	}
+
 	// Query for favorite products
-	rows, err := DB.Query(`
-		SELECT p.id, p.title, p.price, p.category, p.image, f.notes
+	rows, err := s.db.Query(`
+		SELECT `+productColumns+`, f.notes, f.created_at, f.updated_at
 		FROM favorites f
 		JOIN products p ON f.product_id = p.id
 		WHERE f.user_id = ?
 		ORDER BY f.id DESC
-	`, userID)
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("error querying favorites: %w", err)
+		return nil, 0, fmt.Errorf("error querying favorites: %w", err)
 	}
 	defer rows.Close()
 
 	// Parse the results
-	favorites := []models.Product{}
+	favorites := []models.FavoriteProduct{}
 	for rows.Next() {
-		var product models.Product
+		var favorite models.FavoriteProduct
 		var notes sql.NullString
-		err := rows.Scan(
-			&product.ID,
-			&product.Title,
-			&product.Price,
-			&product.Category,
-			&product.Image,
-			&notes,
-		)
+		err := rows.Scan(append(productFields(&favorite.Product), &notes, &favorite.CreatedAt, &favorite.UpdatedAt)...)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning favorite: %w", err)
+			return nil, 0, fmt.Errorf("error scanning favorite: %w", err)
 		}
-		favorites = append(favorites, product)
+		favorite.Notes = notes.String
+		favorites = append(favorites, favorite)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating favorites: %w", err)
+		return nil, 0, fmt.Errorf("error iterating favorites: %w", err)
 	}
 
-	return favorites, nil
+	return favorites, total, nil
 }
 
 // RemoveFavorite removes a product from a user's favorites
-func RemoveFavorite(userID, productID int) error {
-	result, err := DB.Exec("DELETE FROM favorites WHERE user_id = ? AND product_id = ?", userID, productID)
+func (s *SQLiteStore) RemoveFavorite(userID, productID int) error {
+	result, err := s.db.Exec("DELETE FROM favorites WHERE user_id = ? AND product_id = ?", userID, productID)
 	if err != nil {
 		return fmt.Errorf("error removing favorite: %w", err)
 	}
@@ -92,7 +111,7 @@ func RemoveFavorite(userID, productID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("favorite not found")
+		return ErrFavoriteNotFound
 	}
 
 	return nil
@@ -0,0 +1,237 @@
+package db
+
+import (
+	"math"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// The memory store has no full-text search engine, so it ports the parts of
+// SQLite's FTS5 it needs: the bm25 ranking function and the snippet function.
+// Postgres ranks with ts_rank instead but has no equivalent of snippet, so it
+// builds its snippets here too and every backend returns the same ones.
+//
+// The port follows FTS5's source (fts5_aux.c); TestSearchMatchesFTS5 checks
+// it against FTS5 itself.
+
+// searchDocument is the words of a product's searched columns
+type searchDocument [3][]wordSpan
+
+// newSearchDocument splits the searched columns of a product into words
+func newSearchDocument(product *models.Product) searchDocument {
+	return searchDocument{words(product.Title), words(product.Description), words(product.Category)}
+}
+
+// length returns the number of words of the document
+func (d searchDocument) length() int {
+	return len(d[0]) + len(d[1]) + len(d[2])
+}
+
+// frequency returns the weighted number of words of the document that start
+// with term
+func (d searchDocument) frequency(term string) float64 {
+	var frequency float64
+	for column, spans := range d {
+		for _, span := range spans {
+			if strings.HasPrefix(span.word, term) {
+				frequency += searchWeights[column]
+			}
+		}
+	}
+	return frequency
+}
+
+// matches reports whether every term is the prefix of a word of the document
+func (d searchDocument) matches(terms []string) bool {
+	for _, term := range terms {
+		if d.frequency(term) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bm25 scores the documents matching the terms the way FTS5's bm25 function
+// does, with the documents as the whole corpus. Higher scores rank first.
+func bm25(documents []searchDocument, terms []string, matching []int) map[int]float64 {
+	const k1, b = 1.2, 0.75
+
+	if len(documents) == 0 {
+		return map[int]float64{}
+	}
+	var totalLength int
+	for _, document := range documents {
+		totalLength += document.length()
+	}
+	averageLength := float64(totalLength) / float64(len(documents))
+
+	// Inverse document frequency of each term
+	idf := make([]float64, len(terms))
+	for i, term := range terms {
+		var hits int
+		for _, document := range documents {
+			if document.frequency(term) > 0 {
+				hits++
+			}
+		}
+		n := float64(len(documents))
+		idf[i] = math.Log((n - float64(hits) + 0.5) / (float64(hits) + 0.5))
+		if idf[i] <= 0 {
+			idf[i] = 1e-6
+		}
+	}
+
+	scores := map[int]float64{}
+	for _, index := range matching {
+		document := documents[index]
+		var score float64
+		for i, term := range terms {
+			frequency := document.frequency(term)
+			score += idf[i] * frequency * (k1 + 1) /
+				(frequency + k1*(1-b+b*float64(document.length())/averageLength))
+		}
+		scores[index] = score
+	}
+	return scores
+}
+
+// snippet returns up to snippetTokens words of the column of a product where
+// the terms match best, with the matched words between highlight markers and
+// an ellipsis where the column was cut. Windows are chosen the way FTS5's
+// snippet function chooses them, so that every backend returns the same
+// snippets.
+func snippet(terms []string, columns ...string) string {
+	best, bestStart, bestScore := 0, 0, 0
+	for i, column := range columns {
+		spans := words(column)
+		hits := termHits(spans, terms)
+		sentences := sentenceStarts(column, spans)
+		for _, hit := range hits {
+			// Windows starting at a match are centered on the matches they hold
+			score, start := windowScore(hits, hit.position, len(spans))
+			if score > bestScore {
+				best, bestStart, bestScore = i, start, score
+			}
+
+			// Windows starting at the sentence holding the match are preferred
+			if len(spans) > snippetTokens {
+				sentence := sentences[0]
+				for _, s := range sentences[1:] {
+					if s > hit.position {
+						break
+					}
+					sentence = s
+				}
+				if sentence < hit.position {
+					score, _ := windowScore(hits, sentence, len(spans))
+					if sentence == 0 {
+						score += 120
+					} else {
+						score += 100
+					}
+					if score > bestScore {
+						best, bestStart, bestScore = i, sentence, score
+					}
+				}
+			}
+		}
+	}
+
+	text := columns[best]
+	spans := words(text)
+	if len(spans) == 0 {
+		return text
+	}
+	end := bestStart + snippetTokens
+	if end > len(spans) {
+		end = len(spans)
+	}
+
+	var b strings.Builder
+	position := 0
+	if bestStart > 0 {
+		b.WriteString("…")
+		position = spans[bestStart].start
+	}
+	for _, span := range spans[bestStart:end] {
+		b.WriteString(text[position:span.start])
+		if matchesTerm(span.word, terms) {
+			b.WriteString(highlightStart + text[span.start:span.end] + highlightEnd)
+		} else {
+			b.WriteString(text[span.start:span.end])
+		}
+		position = span.end
+	}
+	if end < len(spans) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(text[position:])
+	}
+	return b.String()
+}
+
+// termHit is a word matching a term
+type termHit struct {
+	term     int
+	position int
+}
+
+// termHits returns the words of a column matching each term, in order
+func termHits(spans []wordSpan, terms []string) []termHit {
+	hits := []termHit{}
+	for position, span := range spans {
+		for term, prefix := range terms {
+			if strings.HasPrefix(span.word, prefix) {
+				hits = append(hits, termHit{term, position})
+			}
+		}
+	}
+	return hits
+}
+
+// windowScore scores the snippetTokens words starting at position: a
+// thousand for every term they match and one for every repeated match. It
+// also returns where the window should start to center those matches in a
+// column of size words.
+func windowScore(hits []termHit, position, size int) (score, start int) {
+	seen := map[int]bool{}
+	first, last := -1, 0
+	for _, hit := range hits {
+		if hit.position < position || hit.position >= position+snippetTokens {
+			continue
+		}
+		if seen[hit.term] {
+			score++
+		} else {
+			score += 1000
+			seen[hit.term] = true
+		}
+		if first < 0 {
+			first = hit.position
+		}
+		last = hit.position + 1
+	}
+
+	start = first - (snippetTokens-(last-first))/2
+	if start+snippetTokens > size {
+		start = size - snippetTokens
+	}
+	if start < 0 {
+		start = 0
+	}
+	return score, start
+}
+
+// sentenceStarts returns the positions of the words starting a sentence: the
+// first one and those following a period or a colon
+func sentenceStarts(text string, spans []wordSpan) []int {
+	starts := []int{0}
+	for i := 1; i < len(spans); i++ {
+		before := strings.TrimRight(text[:spans[i].start], " \t\n\r")
+		if len(before) < spans[i].start && (strings.HasSuffix(before, ".") || strings.HasSuffix(before, ":")) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
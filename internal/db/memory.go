@@ -0,0 +1,873 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/password"
+)
+
+// MemoryStore keeps the catalog in memory. It behaves like SQLiteStore,
+// including its ordering and search semantics, but loses everything when
+// the process exits.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users         map[int]*models.User
+	products      map[int]*models.Product
+	categories    map[int]*memoryCategory
+	favorites     []*memoryFavorite
+	refreshTokens []*memoryRefreshToken
+	revokedTokens map[string]time.Time
+
+	// Last assigned IDs; like SQLite AUTOINCREMENT, IDs are never reused
+	lastUserID, lastProductID, lastCategoryID, lastFavoriteID int
+
+	hasher    password.Hasher
+	dummyHash func() string
+}
+
+// memoryCategory is a row of the categories table
+type memoryCategory struct {
+	id       int
+	slug     string
+	name     string
+	parentID int // 0 for top-level categories
+}
+
+// memoryFavorite is a row of the favorites table
+type memoryFavorite struct {
+	id        int
+	userID    int
+	productID int
+	notes     string
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// memoryRefreshToken is a row of the refresh_tokens table
+type memoryRefreshToken struct {
+	userID    int
+	familyID  string
+	tokenHash string
+	expiresAt time.Time
+	revoked   bool
+}
+
+// MemoryStore implements every store
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store. Only the password hasher
+// of the options applies.
+func NewMemoryStore(options Options) *MemoryStore {
+	options = options.withDefaults()
+	return &MemoryStore{
+		users:         map[int]*models.User{},
+		products:      map[int]*models.Product{},
+		categories:    map[int]*memoryCategory{},
+		revokedTokens: map[string]time.Time{},
+		hasher:        options.Hasher,
+		dummyHash:     dummyPasswordHash(options.Hasher),
+	}
+}
+
+// Close releases nothing; the store remains usable
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// currentTimestamp returns the current time with the precision of SQLite's
+// CURRENT_TIMESTAMP
+func currentTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// GetProducts retrieves products with filtering, sorting, and pagination.
+// Searches match products by word prefixes and return a snippet of each
+// product.
+func (s *MemoryStore) GetProducts(q ProductQuery) ([]models.Product, error) {
+	q = q.withDefaults()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q = s.addSubcategories(q)
+
+	// Index every product for ranking, which depends on the whole catalog
+	terms := searchTerms(q.Search)
+	ids := sortedKeys(s.products)
+	documents := make([]searchDocument, len(ids))
+	if len(terms) > 0 {
+		for i, id := range ids {
+			documents[i] = newSearchDocument(s.products[id])
+		}
+	}
+
+	// Filter the products, in ID order
+	popularity := s.favoriteCounts()
+	matches := []models.Product{}
+	matching := []int{}
+	for i, id := range ids {
+		product := s.readProduct(s.products[id], popularity)
+		if !q.matches(&product) {
+			continue
+		}
+		if len(terms) > 0 && !documents[i].matches(terms) {
+			continue
+		}
+		matches = append(matches, product)
+		matching = append(matching, i)
+	}
+
+	// Sort the products; products with equal sort keys stay in ID order
+	keys := q.Sort.keys(terms)
+	scores := map[int]float64{}
+	if keys.has("relevance") {
+		for index, score := range bm25(documents, terms, matching) {
+			scores[ids[index]] = score
+		}
+	}
+	sortStable(matches, func(a, b models.Product) bool { return keys.compare(&a, &b, scores) < 0 })
+
+	results := window(matches, q.Offset, q.Limit)
+	if len(terms) > 0 {
+		for i := range results {
+			results[i].Snippet = highlight(snippet(terms, results[i].Title, results[i].Description, results[i].Category))
+		}
+	}
+	return results, nil
+}
+
+// CountProducts counts the products matching a query, regardless of its
+// pagination
+func (s *MemoryStore) CountProducts(q ProductQuery) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.matchingProducts(q)), nil
+}
+
+// CountProductsByCategory counts the products matching a query in each
+// category, regardless of its pagination
+func (s *MemoryStore) CountProductsByCategory(q ProductQuery) ([]models.CategoryCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, product := range s.matchingProducts(q) {
+		counts[product.Category]++
+	}
+	return newCategoryCounts(counts), nil
+}
+
+// CountProductsByPrice counts the products matching a query in each price
+// range, regardless of its pagination
+func (s *MemoryStore) CountProductsByPrice(q ProductQuery) ([]models.PriceRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[int]int{}
+	for _, product := range s.matchingProducts(q) {
+		counts[priceRangeIndex(product.Price)]++
+	}
+	return newPriceRanges(counts), nil
+}
+
+// matchingProducts returns the stored products matching a query, regardless
+// of its pagination, in no particular order
+func (s *MemoryStore) matchingProducts(q ProductQuery) []*models.Product {
+	q.After = nil
+	q = s.addSubcategories(q)
+	terms := searchTerms(q.Search)
+
+	products := []*models.Product{}
+	for _, product := range s.products {
+		if q.matches(product) && (len(terms) == 0 || newSearchDocument(product).matches(terms)) {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// copyProduct returns a copy of a product that shares none of its attributes
+func copyProduct(product *models.Product) models.Product {
+	copied := *product
+	copied.Attributes = cloneAttributes(product.Attributes)
+	return copied
+}
+
+// readProduct returns a copy of a stored product along with its popularity,
+// given the favorite counts of every product
+func (s *MemoryStore) readProduct(product *models.Product, popularity map[int]int) models.Product {
+	copied := copyProduct(product)
+	copied.Popularity = popularity[product.ID]
+	return copied
+}
+
+// favoriteCounts returns the number of users who favorited each product
+func (s *MemoryStore) favoriteCounts() map[int]int {
+	counts := map[int]int{}
+	for _, favorite := range s.favorites {
+		counts[favorite.productID]++
+	}
+	return counts
+}
+
+// GetProductByID retrieves a product by ID
+func (s *MemoryStore) GetProductByID(id int) (*models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	product, ok := s.products[id]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	copied := s.readProduct(product, s.favoriteCounts())
+	return &copied, nil
+}
+
+// CreateProduct inserts a new product and returns it with its assigned ID
+func (s *MemoryStore) CreateProduct(product models.Product) (*models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureCategory(product.Category)
+	s.lastProductID++
+	product.ID = s.lastProductID
+	product.Popularity = 0
+	product.CreatedAt = currentTimestamp()
+	stored := copyProduct(&product)
+	s.products[product.ID] = &stored
+	created := copyProduct(&product)
+	return &created, nil
+}
+
+// UpdateProduct replaces all fields of an existing product
+func (s *MemoryStore) UpdateProduct(product models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.products[product.ID]
+	if !ok {
+		return ErrProductNotFound
+	}
+	s.ensureCategory(product.Category)
+	product.CreatedAt = existing.CreatedAt
+	stored := copyProduct(&product)
+	s.products[product.ID] = &stored
+	return nil
+}
+
+// DeleteProduct deletes a product along with any favorites referencing it
+func (s *MemoryStore) DeleteProduct(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[id]; !ok {
+		return ErrProductNotFound
+	}
+	delete(s.products, id)
+
+	favorites := s.favorites[:0]
+	for _, favorite := range s.favorites {
+		if favorite.productID != id {
+			favorites = append(favorites, favorite)
+		}
+	}
+	s.favorites = favorites
+	return nil
+}
+
+// addSubcategories returns the query with the descendants of its categories
+// listed along with them, if it includes subcategories
+func (s *MemoryStore) addSubcategories(q ProductQuery) ProductQuery {
+	if !q.Subcategories || len(q.Categories) == 0 {
+		return q
+	}
+
+	ids := map[int]bool{}
+	for _, slug := range q.Categories {
+		if category := s.findCategory(slug); category != nil {
+			ids[category.id] = true
+		}
+	}
+	for added := true; added; {
+		added = false
+		for _, category := range s.categories {
+			if ids[category.parentID] && !ids[category.id] {
+				ids[category.id] = true
+				added = true
+			}
+		}
+	}
+
+	categories := append([]string{}, q.Categories...)
+	for id := range ids {
+		categories = append(categories, s.categories[id].slug)
+	}
+	q.Categories = categories
+	q.Subcategories = false
+	return q
+}
+
+// findCategory returns the category with the given slug, or nil
+func (s *MemoryStore) findCategory(slug string) *memoryCategory {
+	for _, category := range s.categories {
+		if category.slug == slug {
+			return category
+		}
+	}
+	return nil
+}
+
+// ensureCategory creates the category of a product as a top-level category
+// named after its slug, unless it exists
+func (s *MemoryStore) ensureCategory(slug string) {
+	if s.findCategory(slug) == nil {
+		s.lastCategoryID++
+		s.categories[s.lastCategoryID] = &memoryCategory{id: s.lastCategoryID, slug: slug, name: slug}
+	}
+}
+
+// readCategory returns a stored category along with its product count
+func (s *MemoryStore) readCategory(category *memoryCategory) models.Category {
+	read := models.Category{ID: category.id, Slug: category.slug, Name: category.name}
+	if parent, ok := s.categories[category.parentID]; ok {
+		read.Parent = parent.slug
+	}
+	for _, product := range s.products {
+		if product.Category == category.slug {
+			read.ProductCount++
+		}
+	}
+	return read
+}
+
+// GetCategories retrieves every category along with its product count, in ID
+// order
+func (s *MemoryStore) GetCategories() ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := []models.Category{}
+	for _, id := range sortedKeys(s.categories) {
+		categories = append(categories, s.readCategory(s.categories[id]))
+	}
+	return categories, nil
+}
+
+// GetCategory retrieves a category by slug
+func (s *MemoryStore) GetCategory(slug string) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category := s.findCategory(slug)
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+	read := s.readCategory(category)
+	return &read, nil
+}
+
+// findParent returns the ID of the parent category with the given slug, 0
+// for top-level categories
+func (s *MemoryStore) findParent(slug string) (int, error) {
+	if slug == "" {
+		return 0, nil
+	}
+	parent := s.findCategory(slug)
+	if parent == nil {
+		return 0, ErrParentNotFound
+	}
+	return parent.id, nil
+}
+
+// CreateCategory inserts a new category under its parent, if any, and
+// returns it with its assigned ID
+func (s *MemoryStore) CreateCategory(category models.Category) (*models.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parentID, err := s.findParent(category.Parent)
+	if err != nil {
+		return nil, err
+	}
+	if s.findCategory(category.Slug) != nil {
+		return nil, ErrCategoryExists
+	}
+
+	s.lastCategoryID++
+	s.categories[s.lastCategoryID] = &memoryCategory{id: s.lastCategoryID, slug: category.Slug, name: category.Name, parentID: parentID}
+	category.ID = s.lastCategoryID
+	category.ProductCount = 0
+	return &category, nil
+}
+
+// UpdateCategory renames the category with the given slug and moves it under
+// the given parent, or to the top level
+func (s *MemoryStore) UpdateCategory(category models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.findCategory(category.Slug)
+	if stored == nil {
+		return ErrCategoryNotFound
+	}
+	parentID, err := s.findParent(category.Parent)
+	if err != nil {
+		return err
+	}
+
+	// The new parent must not be the category or one of its descendants
+	for ancestor := parentID; ancestor != 0; ancestor = s.categories[ancestor].parentID {
+		if ancestor == stored.id {
+			return ErrCategoryCycle
+		}
+	}
+
+	stored.name = category.Name
+	stored.parentID = parentID
+	return nil
+}
+
+// DeleteCategory deletes a category that has neither products nor
+// subcategories
+func (s *MemoryStore) DeleteCategory(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category := s.findCategory(slug)
+	if category == nil {
+		return ErrCategoryNotFound
+	}
+	for _, product := range s.products {
+		if product.Category == slug {
+			return ErrCategoryInUse
+		}
+	}
+	for _, child := range s.categories {
+		if child.parentID == category.id {
+			return ErrCategoryInUse
+		}
+	}
+
+	delete(s.categories, category.id)
+	return nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *MemoryStore) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// GetUserByID retrieves a user by ID
+func (s *MemoryStore) GetUserByID(id int) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// CreateUser creates a new user with the given role
+func (s *MemoryStore) CreateUser(username, plaintext, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	// Hash the password before locking; hashing is deliberately slow
+	hashedPassword, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return nil, ErrUsernameTaken
+		}
+	}
+
+	s.lastUserID++
+	user := models.User{
+		ID:       s.lastUserID,
+		Username: username,
+		Password: hashedPassword,
+		Role:     role,
+	}
+	stored := user
+	s.users[user.ID] = &stored
+	return &user, nil
+}
+
+// SetUserRole changes the role of an existing user
+func (s *MemoryStore) SetUserRole(id int, role string) error {
+	if !models.ValidRole(role) {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+// UpdatePassword replaces a user's password hash with a fresh hash of the
+// password produced by the store's hasher
+func (s *MemoryStore) UpdatePassword(id int, plaintext string) error {
+	hashedPassword, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Password = hashedPassword
+	return nil
+}
+
+// ValidatePassword validates a password against a stored hash
+func (s *MemoryStore) ValidatePassword(plaintext, hash string) (ok, needsRehash bool) {
+	return validatePassword(s.hasher, plaintext, hash)
+}
+
+// ValidateDummyPassword validates a password against a hash no user has
+func (s *MemoryStore) ValidateDummyPassword(plaintext string) {
+	validatePassword(s.hasher, plaintext, s.dummyHash())
+}
+
+// GetTokenVersion retrieves a user's current token version
+func (s *MemoryStore) GetTokenVersion(id int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	return user.TokenVersion, nil
+}
+
+// IncrementTokenVersion invalidates every access token issued to a user so far
+// and returns the new token version
+func (s *MemoryStore) IncrementTokenVersion(id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	user.TokenVersion++
+	return user.TokenVersion, nil
+}
+
+// AddFavorite adds a product to a user's favorites, or updates the notes of
+// an existing favorite
+func (s *MemoryStore) AddFavorite(userID, productID int, notes string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return ErrProductNotFound
+	}
+	if _, ok := s.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	now := currentTimestamp()
+	for _, favorite := range s.favorites {
+		if favorite.userID == userID && favorite.productID == productID {
+			favorite.notes = notes
+			favorite.updatedAt = now
+			return nil
+		}
+	}
+
+	s.lastFavoriteID++
+	s.favorites = append(s.favorites, &memoryFavorite{
+		id:        s.lastFavoriteID,
+		userID:    userID,
+		productID: productID,
+		notes:     notes,
+		createdAt: now,
+		updatedAt: now,
+	})
+	return nil
+}
+
+// GetFavorites retrieves a page of a user's favorite products, most recent first,
+// along with the total number of favorites
+func (s *MemoryStore) GetFavorites(userID, page, limit int) ([]models.FavoriteProduct, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Favorites are appended in ID order; walk them backwards for the most recent first
+	popularity := s.favoriteCounts()
+	favorites := []models.FavoriteProduct{}
+	for i := len(s.favorites) - 1; i >= 0; i-- {
+		favorite := s.favorites[i]
+		if favorite.userID != userID {
+			continue
+		}
+		product, ok := s.products[favorite.productID]
+		if !ok {
+			continue
+		}
+		favorites = append(favorites, models.FavoriteProduct{
+			Product:   s.readProduct(product, popularity),
+			Notes:     favorite.notes,
+			CreatedAt: favorite.createdAt,
+			UpdatedAt: favorite.updatedAt,
+		})
+	}
+
+	return paginate(favorites, page, limit), len(favorites), nil
+}
+
+// RemoveFavorite removes a product from a user's favorites
+func (s *MemoryStore) RemoveFavorite(userID, productID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, favorite := range s.favorites {
+		if favorite.userID == userID && favorite.productID == productID {
+			s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+			return nil
+		}
+	}
+	return ErrFavoriteNotFound
+}
+
+// CreateRefreshToken stores the hash of a new refresh token in the given family
+func (s *MemoryStore) CreateRefreshToken(userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	return s.insertRefreshToken(userID, familyID, tokenHash, expiresAt)
+}
+
+// insertRefreshToken stores a refresh token, enforcing unique token hashes
+func (s *MemoryStore) insertRefreshToken(userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	if s.findRefreshToken(tokenHash) != nil {
+		return fmt.Errorf("error creating refresh token: duplicate token hash")
+	}
+	s.refreshTokens = append(s.refreshTokens, &memoryRefreshToken{
+		userID:    userID,
+		familyID:  familyID,
+		tokenHash: tokenHash,
+		expiresAt: expiresAt.UTC(),
+	})
+	return nil
+}
+
+// findRefreshToken returns the refresh token stored under tokenHash, or nil
+func (s *MemoryStore) findRefreshToken(tokenHash string) *memoryRefreshToken {
+	for _, token := range s.refreshTokens {
+		if token.tokenHash == tokenHash {
+			return token
+		}
+	}
+	return nil
+}
+
+// RotateRefreshToken revokes the refresh token stored under oldHash and stores
+// newHash in the same family, returning the ID of the token's user.
+//
+// If the old token has already been rotated, every token of its family is
+// revoked and ErrRefreshTokenReused is returned along with the user ID.
+func (s *MemoryStore) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.findRefreshToken(oldHash)
+	if token == nil {
+		return 0, ErrRefreshTokenNotFound
+	}
+
+	if token.revoked {
+		s.revokeRefreshTokens(func(t *memoryRefreshToken) bool { return t.familyID == token.familyID })
+		return token.userID, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(token.expiresAt) {
+		return 0, ErrRefreshTokenExpired
+	}
+
+	if err := s.insertRefreshToken(token.userID, token.familyID, newHash, expiresAt); err != nil {
+		return 0, err
+	}
+	token.revoked = true
+
+	return token.userID, nil
+}
+
+// revokeRefreshTokens revokes every refresh token matching the predicate
+func (s *MemoryStore) revokeRefreshTokens(match func(*memoryRefreshToken) bool) {
+	for _, token := range s.refreshTokens {
+		if match(token) {
+			token.revoked = true
+		}
+	}
+}
+
+// RevokeRefreshTokenFamily revokes the family of the refresh token stored
+// under tokenHash, provided the token belongs to the given user
+func (s *MemoryStore) RevokeRefreshTokenFamily(userID int, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.findRefreshToken(tokenHash)
+	if token == nil || token.userID != userID {
+		return nil
+	}
+	s.revokeRefreshTokens(func(t *memoryRefreshToken) bool { return t.familyID == token.familyID })
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token of a user
+func (s *MemoryStore) RevokeUserRefreshTokens(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(t *memoryRefreshToken) bool { return t.userID == userID })
+	return nil
+}
+
+// DeleteExpiredRefreshTokens removes expired refresh tokens, returning how many
+// were removed
+func (s *MemoryStore) DeleteExpiredRefreshTokens() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	tokens := s.refreshTokens[:0]
+	for _, token := range s.refreshTokens {
+		if token.expiresAt.After(now) {
+			tokens = append(tokens, token)
+		} else {
+			deleted++
+		}
+	}
+	s.refreshTokens = tokens
+	return deleted, nil
+}
+
+// RevokeToken records an access token as revoked until it expires
+func (s *MemoryStore) RevokeToken(jti string, userID int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	// Like INSERT OR IGNORE, the first revocation wins
+	if _, ok := s.revokedTokens[jti]; !ok {
+		s.revokedTokens[jti] = expiresAt.UTC()
+	}
+	return nil
+}
+
+// GetRevokedTokens retrieves the IDs and expiry times of all revoked access
+// tokens that have not expired yet
+func (s *MemoryStore) GetRevokedTokens() (map[string]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	revoked := map[string]time.Time{}
+	for jti, expiresAt := range s.revokedTokens {
+		if expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	return revoked, nil
+}
+
+// DeleteExpiredRevokedTokens removes revoked access tokens that have expired
+// anyway, returning how many were removed
+func (s *MemoryStore) DeleteExpiredRevokedTokens() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for jti, expiresAt := range s.revokedTokens {
+		if !expiresAt.After(now) {
+			delete(s.revokedTokens, jti)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// sortStable sorts items by less, keeping the order of equal items
+func sortStable[T any](items []T, less func(a, b T) bool) {
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+}
+
+// paginate returns the given page of items
+func paginate[T any](items []T, page, limit int) []T {
+	return window(items, (page-1)*limit, limit)
+}
+
+// window returns up to limit items after the first offset ones
+func window[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
@@ -0,0 +1,479 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the migrations of each SQL backend, in
+// migrations/<backend>/<version>_<name>.up.sql and .down.sql files
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var (
+	// ErrSchemaTooNew is returned when the database has migrations applied
+	// that this build doesn't know, i.e. it was migrated by a newer version
+	ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+	// ErrMigrationModified is returned when an applied migration no longer
+	// matches the migration of the same version in this build
+	ErrMigrationModified = errors.New("applied migration was modified")
+)
+
+// Migration is a versioned change to the database schema
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up, recorded when the migration is applied
+}
+
+// MigrationStatus is a migration along with when it was applied, if it was
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies and reverts the migrations of a SQL database
+type Migrator struct {
+	db         *sql.DB
+	backend    string
+	migrations []Migration
+}
+
+// OpenMigrator connects to the database of the given driver and source, as
+// Open does, without migrating it
+func OpenMigrator(driver, source string, options Options) (*Migrator, error) {
+	options = options.withDefaults()
+	var conn *sql.DB
+	var err error
+	switch driver {
+	case "sqlite":
+		conn, err = sql.Open("sqlite", options.sqliteDSN(source))
+	case "postgres":
+		conn, err = sql.Open("pgx", source)
+	case "memory":
+		return nil, errors.New("the memory driver has no schema to migrate")
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	options.configurePool(conn)
+
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+
+	m, err := newMigrator(conn, driver)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMigrator returns a migrator for the database of the given backend and
+// creates its schema_migrations table if it doesn't exist
+func newMigrator(conn *sql.DB, backend string) (*Migrator, error) {
+	migrations, err := loadMigrations(backend)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: conn, backend: backend, migrations: migrations}
+
+	appliedAt := "DATETIME"
+	if backend == "postgres" {
+		appliedAt = "TIMESTAMPTZ"
+	}
+	_, err = conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at ` + appliedAt + ` NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return m, nil
+}
+
+// loadMigrations reads the migrations of a backend, ordered by version. Versions
+// must start at 1 and have no gaps, and every migration needs both files.
+func loadMigrations(backend string) ([]Migration, error) {
+	dir := path.Join("migrations", backend)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		version, label, found := strings.Cut(name, "_")
+		number, err := strconv.Atoi(version)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		contents, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+
+		migration := byVersion[number]
+		if migration == nil {
+			migration = &Migration{Version: number, Name: label}
+			byVersion[number] = migration
+		}
+		if direction == "up" {
+			sum := sha256.Sum256(contents)
+			migration.Up = string(contents)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := []Migration{}
+	for version := 1; version <= len(byVersion); version++ {
+		migration := byVersion[version]
+		if migration == nil {
+			return nil, fmt.Errorf("migration %d is missing", version)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// Close closes the database connection
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// rebind replaces the ? placeholders of a query with the backend's own
+func (m *Migrator) rebind(query string) string {
+	if m.backend != "postgres" {
+		return query
+	}
+	return postgresPlaceholders(query)
+}
+
+// applied retrieves the applied migrations by version
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	rows, err := m.db.Query("SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error querying schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var migration appliedMigration
+		if err := rows.Scan(&version, &migration.name, &migration.checksum, &migration.appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema migration: %w", err)
+		}
+		applied[version] = migration
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// check verifies that every applied migration is known and unmodified
+func (m *Migrator) check(applied map[int]appliedMigration) error {
+	for version, migration := range applied {
+		if version > len(m.migrations) {
+			return fmt.Errorf("%w: version %d (%s) is applied, this build only knows up to %d",
+				ErrSchemaTooNew, version, migration.name, len(m.migrations))
+		}
+		if known := m.migrations[version-1]; migration.checksum != known.Checksum {
+			return fmt.Errorf("%w: version %d (%s)", ErrMigrationModified, version, known.Name)
+		}
+	}
+	return nil
+}
+
+// Check verifies that the database schema is one this build can run against
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	return m.check(applied)
+}
+
+// Status lists every known migration and whether it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.check(applied); err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if a, ok := applied[migration.Version]; ok {
+			appliedAt := a.appliedAt.UTC()
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the migrations it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.check(applied); err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		ran, err := m.apply(migration)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// apply runs a migration and records it, unless a concurrent migrator applied
+// it first. It reports whether the migration was run.
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.lock(tx); err != nil {
+		return false, err
+	}
+
+	var exists int
+	err = tx.QueryRow(m.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), migration.Version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error querying schema migrations: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	if migration.Version == 1 && m.backend == "sqlite" {
+		if err := upgradeLegacySchema(tx); err != nil {
+			return false, fmt.Errorf("error upgrading legacy schema: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(migration.Up); err != nil {
+		return false, fmt.Errorf("error applying migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.Exec(
+		m.rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+		migration.Version, migration.Name, migration.Checksum, time.Now().UTC().Truncate(time.Second),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error recording migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	return true, nil
+}
+
+// upgradeLegacySchema adds the columns that SQLite databases created before
+// migrations were introduced may lack, so that the initial migration finds
+// the schema it would have created
+func upgradeLegacySchema(tx *sql.Tx) error {
+	var legacy bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'users')").Scan(&legacy)
+	if err != nil {
+		return fmt.Errorf("error reading schema: %w", err)
+	}
+	if !legacy {
+		return nil
+	}
+
+	// Databases created before roles were introduced lack the role column
+	if _, err = addColumnIfMissing(tx, "users", "role", "TEXT NOT NULL DEFAULT 'user'"); err != nil {
+		return fmt.Errorf("error adding users.role column: %w", err)
+	}
+	if _, err = addColumnIfMissing(tx, "users", "token_version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("error adding users.token_version column: %w", err)
+	}
+
+	// Databases created before favorites were timestamped lack these columns.
+	// SQLite cannot add a column with a CURRENT_TIMESTAMP default, so the
+	// existing rows are backfilled instead.
+	for _, column := range []string{"created_at", "updated_at"} {
+		added, err := addColumnIfMissing(tx, "favorites", column, "DATETIME")
+		if err != nil {
+			return fmt.Errorf("error adding favorites.%s column: %w", column, err)
+		}
+		if added {
+			_, err = tx.Exec("UPDATE favorites SET " + column + " = CURRENT_TIMESTAMP WHERE " + column + " IS NULL")
+			if err != nil {
+				return fmt.Errorf("error backfilling favorites.%s: %w", column, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing SQLite table unless it is
+// already present, reporting whether the column was added
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) (bool, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, fmt.Errorf("error reading table info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, fmt.Errorf("error scanning table info: %w", err)
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error iterating table info: %w", err)
+	}
+	rows.Close()
+
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Down reverts the last steps applied migrations, most recent first, each in
+// its own transaction, and returns the migrations it reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.check(applied); err != nil {
+		return nil, err
+	}
+
+	versions := []int{}
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	done := []Migration{}
+	for _, version := range versions {
+		migration := m.migrations[version-1]
+		if err := m.revert(migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// revert runs the down migration of an applied migration and forgets it
+func (m *Migrator) revert(migration Migration) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.lock(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(migration.Down); err != nil {
+		return fmt.Errorf("error reverting migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.Exec(m.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version); err != nil {
+		return fmt.Errorf("error forgetting migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// lock serializes migrations of several processes. SQLite needs no lock of its
+// own since it allows a single writer anyway.
+func (m *Migrator) lock(tx *sql.Tx) error {
+	if m.backend != "postgres" {
+		return nil
+	}
+	if _, err := tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("error locking schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// migrate checks the schema of a store's database and applies the pending
+// migrations
+func migrate(conn *sql.DB, backend string) error {
+	m, err := newMigrator(conn, backend)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up()
+	return err
+}
@@ -0,0 +1,54 @@
+-- Users, products and favorites, refresh tokens and revoked access tokens.
+-- Timestamps defaulting to the current time are kept to the second, like
+-- SQLite's CURRENT_TIMESTAMP.
+
+CREATE TABLE users (
+	id SERIAL PRIMARY KEY,
+	username TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'user',
+	token_version INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE products (
+	id SERIAL PRIMARY KEY,
+	title TEXT NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	category TEXT NOT NULL,
+	image TEXT NOT NULL
+);
+
+CREATE TABLE favorites (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	notes TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT date_trunc('second', CURRENT_TIMESTAMP),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT date_trunc('second', CURRENT_TIMESTAMP),
+	FOREIGN KEY (user_id) REFERENCES users (id),
+	FOREIGN KEY (product_id) REFERENCES products (id),
+	UNIQUE (user_id, product_id)
+);
+
+-- Tokens are stored hashed; rotated tokens are kept (revoked) so that their
+-- reuse can be detected
+CREATE TABLE refresh_tokens (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMPTZ,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+-- IDs of access tokens revoked before their expiry (e.g. on logout)
+CREATE TABLE revoked_tokens (
+	jti TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_products_search;
+ALTER TABLE products DROP COLUMN search;
+ALTER TABLE products DROP COLUMN description;
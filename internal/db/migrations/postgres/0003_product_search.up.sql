@@ -0,0 +1,12 @@
+-- Products get a description, and are searched by a generated text search
+-- vector of their title, category and description. Everything but letters and
+-- digits separates words, like SQLite's FTS5 unicode61 tokenizer.
+ALTER TABLE products ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE products ADD COLUMN search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple', regexp_replace(title, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+	setweight(to_tsvector('simple', regexp_replace(category, '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+	setweight(to_tsvector('simple', regexp_replace(description, '[^[:alnum:]]+', ' ', 'g')), 'D')
+) STORED;
+
+CREATE INDEX idx_products_search ON products USING GIN (search);
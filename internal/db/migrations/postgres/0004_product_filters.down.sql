@@ -0,0 +1,3 @@
+DROP INDEX idx_products_attributes;
+ALTER TABLE products DROP COLUMN attributes;
+ALTER TABLE products DROP COLUMN stock;
@@ -0,0 +1,6 @@
+-- Products get a stock count and free-form attributes, stored as a JSON object
+-- indexed for containment queries
+ALTER TABLE products ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_products_attributes ON products USING GIN (attributes);
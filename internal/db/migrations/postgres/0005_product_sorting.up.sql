@@ -0,0 +1,8 @@
+-- Products get an average customer rating, and are sorted by title, rating or
+-- number of favorites, which are counted per product. Titles are sorted like
+-- SQLite's NOCASE collation: bytewise, with ASCII letters lowercased.
+ALTER TABLE products ADD COLUMN rating DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_products_title ON products ((translate(title, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz') COLLATE "C"));
+CREATE INDEX idx_products_rating ON products (rating);
+CREATE INDEX idx_favorites_product_id ON favorites (product_id);
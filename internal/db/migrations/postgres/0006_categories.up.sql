@@ -0,0 +1,13 @@
+-- Categories form a tree; products refer to theirs by slug. The categories
+-- products already use become top-level categories named after their slug.
+CREATE TABLE categories (
+	id SERIAL PRIMARY KEY,
+	slug TEXT UNIQUE NOT NULL,
+	name TEXT NOT NULL,
+	parent_id INTEGER,
+	FOREIGN KEY (parent_id) REFERENCES categories (id)
+);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+INSERT INTO categories (slug, name) SELECT DISTINCT category, category FROM products ORDER BY category;
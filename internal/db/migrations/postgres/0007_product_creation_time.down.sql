@@ -0,0 +1,2 @@
+DROP INDEX idx_products_created_at;
+ALTER TABLE products DROP COLUMN created_at;
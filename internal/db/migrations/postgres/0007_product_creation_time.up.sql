@@ -0,0 +1,6 @@
+-- Products are sorted from newest to oldest by creation time; the existing
+-- products are all given the time of the migration, and products created in
+-- the same second keep their ID order
+ALTER TABLE products ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT date_trunc('second', CURRENT_TIMESTAMP);
+
+CREATE INDEX idx_products_created_at ON products (created_at, id);
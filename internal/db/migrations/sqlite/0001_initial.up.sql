@@ -0,0 +1,54 @@
+-- Users, products and favorites, refresh tokens and revoked access tokens.
+-- Tables may already exist in databases created before migrations were
+-- introduced.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'user',
+	token_version INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	price REAL NOT NULL,
+	category TEXT NOT NULL,
+	image TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS favorites (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	notes TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id),
+	FOREIGN KEY (product_id) REFERENCES products (id),
+	UNIQUE (user_id, product_id)
+);
+
+-- Tokens are stored hashed; rotated tokens are kept (revoked) so that their
+-- reuse can be detected
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	revoked_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+-- IDs of access tokens revoked before their expiry (e.g. on logout)
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+	jti TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_products_price;
+DROP INDEX idx_products_category;
+DROP INDEX idx_favorites_user_id;
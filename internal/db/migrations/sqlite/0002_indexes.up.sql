@@ -0,0 +1,5 @@
+-- Favorites are listed per user, and products filtered by category and
+-- sorted by price
+CREATE INDEX idx_favorites_user_id ON favorites (user_id);
+CREATE INDEX idx_products_category ON products (category);
+CREATE INDEX idx_products_price ON products (price);
@@ -0,0 +1,30 @@
+-- Products get a description, and are searched with an FTS5 index of their
+-- title, description and category that triggers keep in sync
+ALTER TABLE products ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+CREATE VIRTUAL TABLE products_fts USING fts5(
+	title, description, category,
+	content = 'products', content_rowid = 'id',
+	tokenize = 'unicode61 remove_diacritics 0',
+	prefix = '2 3'
+);
+
+CREATE TRIGGER products_fts_insert AFTER INSERT ON products BEGIN
+	INSERT INTO products_fts (rowid, title, description, category)
+	VALUES (new.id, new.title, new.description, new.category);
+END;
+
+CREATE TRIGGER products_fts_delete AFTER DELETE ON products BEGIN
+	INSERT INTO products_fts (products_fts, rowid, title, description, category)
+	VALUES ('delete', old.id, old.title, old.description, old.category);
+END;
+
+CREATE TRIGGER products_fts_update AFTER UPDATE ON products BEGIN
+	INSERT INTO products_fts (products_fts, rowid, title, description, category)
+	VALUES ('delete', old.id, old.title, old.description, old.category);
+	INSERT INTO products_fts (rowid, title, description, category)
+	VALUES (new.id, new.title, new.description, new.category);
+END;
+
+-- Index the existing products
+INSERT INTO products_fts (products_fts) VALUES ('rebuild');
@@ -0,0 +1,3 @@
+-- Products get a stock count and free-form attributes, stored as a JSON object
+ALTER TABLE products ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN attributes TEXT NOT NULL DEFAULT '{}';
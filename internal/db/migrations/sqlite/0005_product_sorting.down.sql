@@ -0,0 +1,4 @@
+DROP INDEX idx_favorites_product_id;
+DROP INDEX idx_products_rating;
+DROP INDEX idx_products_title;
+ALTER TABLE products DROP COLUMN rating;
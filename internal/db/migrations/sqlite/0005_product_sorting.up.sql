@@ -0,0 +1,7 @@
+-- Products get an average customer rating, and are sorted by title, rating or
+-- number of favorites, which are counted per product
+ALTER TABLE products ADD COLUMN rating REAL NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_products_title ON products (title COLLATE NOCASE);
+CREATE INDEX idx_products_rating ON products (rating);
+CREATE INDEX idx_favorites_product_id ON favorites (product_id);
@@ -0,0 +1,2 @@
+DROP INDEX idx_categories_parent_id;
+DROP TABLE categories;
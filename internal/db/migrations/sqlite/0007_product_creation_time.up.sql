@@ -0,0 +1,9 @@
+-- Products are sorted from newest to oldest by creation time. SQLite cannot
+-- add a column with a CURRENT_TIMESTAMP default, so products are given theirs
+-- on insert and the existing ones are backfilled; products created in the same
+-- second keep their ID order.
+ALTER TABLE products ADD COLUMN created_at DATETIME;
+
+UPDATE products SET created_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_products_created_at ON products (created_at, id);
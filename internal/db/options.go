@@ -0,0 +1,85 @@
+package db
+
+import (
+	"database/sql"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/password"
+)
+
+// Options tunes the database connections and how passwords are stored.
+// Zero values select the defaults.
+type Options struct {
+	// JournalMode is the SQLite journal mode, WAL by default so that reads
+	// don't wait for writes
+	JournalMode string
+
+	// BusyTimeout is how long SQLite waits for a lock held by another
+	// connection before failing with SQLITE_BUSY, 5 seconds by default
+	BusyTimeout time.Duration
+
+	// MaxOpenConns limits the number of open connections, 10 by default
+	MaxOpenConns int
+
+	// MaxIdleConns limits the number of idle connections kept open,
+	// MaxOpenConns by default
+	MaxIdleConns int
+
+	// Hasher hashes new passwords, argon2id by default
+	Hasher password.Hasher
+}
+
+// journalModes are the journal modes SQLite supports
+var journalModes = []string{"wal", "delete", "truncate", "persist", "memory", "off"}
+
+// ValidJournalMode reports whether mode is a SQLite journal mode
+func ValidJournalMode(mode string) bool {
+	for _, m := range journalModes {
+		if strings.EqualFold(mode, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults returns the options with defaults for the zero values
+func (o Options) withDefaults() Options {
+	if o.JournalMode == "" {
+		o.JournalMode = "wal"
+	}
+	if o.BusyTimeout == 0 {
+		o.BusyTimeout = 5 * time.Second
+	}
+	if o.MaxOpenConns == 0 {
+		o.MaxOpenConns = 10
+	}
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = o.MaxOpenConns
+	}
+	if o.Hasher == nil {
+		o.Hasher = password.Default()
+	}
+	return o
+}
+
+// sqliteDSN returns the data source name of the SQLite database at path,
+// setting up every connection of the pool: foreign keys are enforced, and
+// transactions take the write lock up front so that a transaction that
+// reads before writing waits for other writers instead of failing
+func (o Options) sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout("+strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10)+")")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode("+strings.ToLower(o.JournalMode)+")")
+	query.Set("_txlock", "immediate")
+	return path + "?" + query.Encode()
+}
+
+// configurePool applies the connection pool settings
+func (o Options) configurePool(conn *sql.DB) {
+	conn.SetMaxOpenConns(o.MaxOpenConns)
+	conn.SetMaxIdleConns(o.MaxIdleConns)
+}
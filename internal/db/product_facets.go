@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// priceRangeBounds are the lower bounds of the price ranges products are
+// counted in; each range ends where the next one starts
+var priceRangeBounds = []float64{0, 10, 25, 50, 100, 250, 500, 1000}
+
+// priceRangeColumn is the index of the price range of product p
+func priceRangeColumn() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range priceRangeBounds[1:] {
+		fmt.Fprintf(&b, " WHEN p.price < %g THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(priceRangeBounds)-1)
+	return b.String()
+}
+
+// priceRangeIndex returns the index of the price range of a price, as
+// priceRangeColumn does
+func priceRangeIndex(price float64) int {
+	for i, bound := range priceRangeBounds[1:] {
+		if price < bound {
+			return i
+		}
+	}
+	return len(priceRangeBounds) - 1
+}
+
+// newPriceRanges returns every price range with its count, given the counts
+// by range index
+func newPriceRanges(counts map[int]int) []models.PriceRange {
+	ranges := make([]models.PriceRange, len(priceRangeBounds))
+	for i, bound := range priceRangeBounds {
+		ranges[i] = models.PriceRange{Min: bound, Count: counts[i]}
+		if i+1 < len(priceRangeBounds) {
+			upper := priceRangeBounds[i+1]
+			ranges[i].Max = &upper
+		}
+	}
+	return ranges
+}
+
+// newCategoryCounts returns the counts of the categories that have products,
+// most products first, then by slug
+func newCategoryCounts(counts map[string]int) []models.CategoryCount {
+	categories := []models.CategoryCount{}
+	for category, count := range counts {
+		if count > 0 {
+			categories = append(categories, models.CategoryCount{Category: category, Count: count})
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Count != categories[j].Count {
+			return categories[i].Count > categories[j].Count
+		}
+		return categories[i].Category < categories[j].Category
+	})
+	return categories
+}
+
+// scanGroupCounts reads rows of a key and a count into counts
+func scanGroupCounts[K comparable](rows *sql.Rows, counts map[K]int) error {
+	defer rows.Close()
+	for rows.Next() {
+		var key K
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("error scanning count: %w", err)
+		}
+		counts[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating counts: %w", err)
+	}
+	return nil
+}
+
+// CountProductsByCategory counts the products matching a query in each
+// category, regardless of its pagination
+func (s *SQLiteStore) CountProductsByCategory(q ProductQuery) ([]models.CategoryCount, error) {
+	q.After = nil
+	from, where, args := s.productFilter(q)
+
+	rows, err := s.db.Query("SELECT p.category, COUNT(*)"+from+where+" GROUP BY p.category", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting products by category: %w", err)
+	}
+	counts := map[string]int{}
+	if err := scanGroupCounts(rows, counts); err != nil {
+		return nil, err
+	}
+	return newCategoryCounts(counts), nil
+}
+
+// CountProductsByPrice counts the products matching a query in each price
+// range, regardless of its pagination
+func (s *SQLiteStore) CountProductsByPrice(q ProductQuery) ([]models.PriceRange, error) {
+	q.After = nil
+	from, where, args := s.productFilter(q)
+
+	rows, err := s.db.Query("SELECT "+priceRangeColumn()+", COUNT(*)"+from+where+" GROUP BY 1", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting products by price: %w", err)
+	}
+	counts := map[int]int{}
+	if err := scanGroupCounts(rows, counts); err != nil {
+		return nil, err
+	}
+	return newPriceRanges(counts), nil
+}
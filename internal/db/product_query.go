@@ -0,0 +1,295 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// ProductQuery selects a page of products. Zero values don't filter.
+type ProductQuery struct {
+	// Offset skips products, Limit caps the number of products returned, 10
+	// by default
+	Offset, Limit int
+
+	// After resumes the listing after the product a cursor was taken from,
+	// instead of skipping products
+	After *ProductCursor
+
+	// Categories lists the slugs of the categories to include; products of
+	// any of them match
+	Categories []string
+
+	// Subcategories extends Categories to the descendants of the categories
+	Subcategories bool
+
+	// Search is a full-text search, see searchTerms
+	Search string
+
+	// Sort orders the products, in ID order by default
+	Sort Sort
+
+	// MinPrice and MaxPrice bound the price, inclusively
+	MinPrice, MaxPrice *float64
+
+	// InStock selects only products with units available
+	InStock bool
+
+	// Attributes lists the accepted values of each attribute; products
+	// must have one of the values of every attribute
+	Attributes map[string][]string
+}
+
+// ProductCursor is the position of a product in a listing: the values of its
+// sort keys and its ID, which breaks ties
+type ProductCursor struct {
+	Sort       string     `json:"sort"`
+	Price      float64    `json:"price,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Popularity int        `json:"popularity,omitempty"`
+	Rating     float64    `json:"rating,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ID         int        `json:"id"`
+}
+
+// product returns a product with the sort keys of the cursor
+func (c *ProductCursor) product() *models.Product {
+	product := &models.Product{ID: c.ID, Title: c.Title, Price: c.Price, Popularity: c.Popularity, Rating: c.Rating}
+	if c.CreatedAt != nil {
+		product.CreatedAt = *c.CreatedAt
+	}
+	return product
+}
+
+// withDefaults returns the query with a limit of 10 products when none is
+// given
+func (q ProductQuery) withDefaults() ProductQuery {
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	if q.Limit < 1 {
+		q.Limit = 10
+	}
+	return q
+}
+
+// SupportsCursor reports whether the listing can be resumed from a cursor,
+// which needs an order on columns. Relevance ranks depend on the whole
+// catalog, so searches sorted by relevance don't.
+func (q ProductQuery) SupportsCursor() bool {
+	return !q.Sort.has("relevance") || len(searchTerms(q.Search)) == 0
+}
+
+// CursorAfter returns the cursor resuming the listing after product
+func (q ProductQuery) CursorAfter(product models.Product) *ProductCursor {
+	cursor := &ProductCursor{Sort: q.Sort.String(), ID: product.ID}
+	for _, key := range q.Sort {
+		switch key.Field {
+		case "price":
+			cursor.Price = product.Price
+		case "title":
+			cursor.Title = product.Title
+		case "popularity":
+			cursor.Popularity = product.Popularity
+		case "rating":
+			cursor.Rating = product.Rating
+		case "newest":
+			createdAt := product.CreatedAt
+			cursor.CreatedAt = &createdAt
+		}
+	}
+	return cursor
+}
+
+// attributeNames returns the names of the filtered attributes, sorted so
+// that queries are built the same way every time
+func (q ProductQuery) attributeNames() []string {
+	names := make([]string, 0, len(q.Attributes))
+	for name := range q.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// columnConditions returns the conditions of the query on the columns of
+// products p, with ? placeholders, along with their arguments. The condition
+// on the cursor is left to afterCondition.
+func (q ProductQuery) columnConditions() ([]string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if len(q.Categories) > 0 && q.Subcategories {
+		conditions = append(conditions, "p.category IN ("+fmt.Sprintf(subcategoriesQuery, placeholders(len(q.Categories)))+")")
+		for _, category := range q.Categories {
+			args = append(args, category)
+		}
+	} else if len(q.Categories) > 0 {
+		conditions = append(conditions, "p.category IN ("+placeholders(len(q.Categories))+")")
+		for _, category := range q.Categories {
+			args = append(args, category)
+		}
+	}
+	if q.MinPrice != nil {
+		conditions = append(conditions, "p.price >= ?")
+		args = append(args, *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		conditions = append(conditions, "p.price <= ?")
+		args = append(args, *q.MaxPrice)
+	}
+	if q.InStock {
+		conditions = append(conditions, "p.stock > 0")
+	}
+	return conditions, args
+}
+
+// matches reports whether a product passes the filters of the query other
+// than the search, and comes after its cursor. Subcategories must have been
+// added to the categories already.
+func (q ProductQuery) matches(product *models.Product) bool {
+	if len(q.Categories) > 0 && !contains(q.Categories, product.Category) {
+		return false
+	}
+	if q.MinPrice != nil && product.Price < *q.MinPrice {
+		return false
+	}
+	if q.MaxPrice != nil && product.Price > *q.MaxPrice {
+		return false
+	}
+	if q.InStock && product.Stock <= 0 {
+		return false
+	}
+	if q.After != nil && q.Sort.keys(nil).compare(product, q.After.product(), nil) <= 0 {
+		return false
+	}
+	for name, values := range q.Attributes {
+		value, ok := product.Attributes[name]
+		if !ok || !contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// postgresPlaceholders replaces the ? placeholders of a query with Postgres'
+// numbered ones
+func postgresPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// productColumns are the columns of products p read into a models.Product by
+// productFields
+const productColumns = "p.id, p.title, p.description, p.price, p.category, p.image, p.stock, p.attributes, p.rating, " +
+	favoriteCountColumn + ", p.created_at"
+
+// listingColumns are productColumns for listings, which join popularityJoin
+const listingColumns = "p.id, p.title, p.description, p.price, p.category, p.image, p.stock, p.attributes, p.rating, " +
+	popularityColumn + ", p.created_at"
+
+// productFields returns the destinations to scan productColumns into
+func productFields(product *models.Product) []interface{} {
+	return []interface{}{
+		&product.ID,
+		&product.Title,
+		&product.Description,
+		&product.Price,
+		&product.Category,
+		&product.Image,
+		&product.Stock,
+		attributesColumn{&product.Attributes},
+		&product.Rating,
+		&product.Popularity,
+		timestampColumn{&product.CreatedAt},
+	}
+}
+
+// timestampColumn scans a timestamp in UTC, whatever the time zone of the
+// database session
+type timestampColumn struct {
+	timestamp *time.Time
+}
+
+// Scan implements sql.Scanner
+func (c timestampColumn) Scan(src interface{}) error {
+	timestamp, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("unexpected timestamp of type %T", src)
+	}
+	*c.timestamp = timestamp.UTC()
+	return nil
+}
+
+// attributesColumn scans the JSON object of a product's attributes
+type attributesColumn struct {
+	attributes *map[string]string
+}
+
+// Scan implements sql.Scanner
+func (c attributesColumn) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("unexpected attributes of type %T", src)
+	}
+
+	attributes := map[string]string{}
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return fmt.Errorf("error decoding attributes: %w", err)
+	}
+	*c.attributes = attributes
+	return nil
+}
+
+// attributesJSON encodes a product's attributes to store them
+func attributesJSON(attributes map[string]string) string {
+	if attributes == nil {
+		return "{}"
+	}
+	data, _ := json.Marshal(attributes)
+	return string(data)
+}
+
+// cloneAttributes returns a copy of a product's attributes, empty rather than
+// nil when the product has none
+func cloneAttributes(attributes map[string]string) map[string]string {
+	clone := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		clone[name] = value
+	}
+	return clone
+}
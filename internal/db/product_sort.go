@@ -0,0 +1,297 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// SortKey orders products on a field, in the field's own order unless
+// Descending
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Sort lists the keys products are ordered on, in turn. Products equal on
+// every key are kept in ID order, except that those created in the same
+// second follow the direction of newest.
+type Sort []SortKey
+
+// sortField is a field products can be sorted on
+type sortField struct {
+	// sqlite and postgres are the expressions of the field on products p
+	sqlite, postgres string
+
+	// descending is set for fields sorted in descending order of their
+	// expression, such as newest
+	descending bool
+
+	// value returns the value of the field for a product, compared the way
+	// the expressions are
+	value func(product *models.Product) interface{}
+}
+
+// popularityJoin joins the number of users who favorited each product to
+// products p of listings, counting the favorites of every product at once
+// rather than one product at a time
+const popularityJoin = " LEFT JOIN (SELECT product_id, COUNT(*) AS favorites FROM favorites GROUP BY product_id) pc ON pc.product_id = p.id"
+
+// popularityColumn is the number of users who favorited product p, given
+// popularityJoin
+const popularityColumn = "COALESCE(pc.favorites, 0)"
+
+// favoriteCountColumn is the number of users who favorited product p, counted
+// on its own, which is cheaper than popularityJoin when reading few products
+const favoriteCountColumn = "(SELECT COUNT(*) FROM favorites pf WHERE pf.product_id = p.id)"
+
+// sqliteTimestampFormat is the format of SQLite's CURRENT_TIMESTAMP
+const sqliteTimestampFormat = "2006-01-02 15:04:05"
+
+// sortFields are the fields products can be sorted on, besides relevance.
+// Titles are compared regardless of ASCII case, like SQLite's NOCASE
+// collation, which Postgres mimics by folding titles itself.
+var sortFields = map[string]sortField{
+	"price": {
+		sqlite:   "p.price",
+		postgres: "p.price",
+		value:    func(p *models.Product) interface{} { return p.Price },
+	},
+	"title": {
+		sqlite:   "p.title COLLATE NOCASE",
+		postgres: `translate(p.title, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz') COLLATE "C"`,
+		value:    func(p *models.Product) interface{} { return foldTitle(p.Title) },
+	},
+	"newest": {
+		sqlite:     "p.created_at",
+		postgres:   "p.created_at",
+		descending: true,
+		value:      func(p *models.Product) interface{} { return p.CreatedAt },
+	},
+	"popularity": {
+		sqlite:   popularityColumn,
+		postgres: popularityColumn,
+		value:    func(p *models.Product) interface{} { return p.Popularity },
+	},
+	"rating": {
+		sqlite:   "p.rating",
+		postgres: "p.rating",
+		value:    func(p *models.Product) interface{} { return p.Rating },
+	},
+}
+
+// creationOrder breaks ties between products created in the same second,
+// sorting them in the direction of newest. It is not a field clients can
+// sort on.
+var creationOrder = sortField{
+	sqlite:     "p.id",
+	postgres:   "p.id",
+	descending: true,
+	value:      func(p *models.Product) interface{} { return p.ID },
+}
+
+// sortFieldNames lists the fields that can be sorted on, for error messages
+const sortFieldNames = "price, title, newest, popularity, rating and relevance"
+
+// ParseSort parses a comma-separated list of fields, each prefixed with - to
+// reverse its order, as in -price,title. The former price_asc and price_desc
+// values stand for price and -price.
+func ParseSort(value string) (Sort, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "price_asc":
+		return Sort{{Field: "price"}}, nil
+	case "price_desc":
+		return Sort{{Field: "price", Descending: true}}, nil
+	}
+
+	sort := Sort{}
+	seen := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		field, descending := strings.CutPrefix(item, "-")
+		if _, ok := sortFields[field]; !ok && field != "relevance" {
+			return nil, fmt.Errorf("sort field %q is unknown; products can be sorted by %s", field, sortFieldNames)
+		}
+		if field == "relevance" && descending {
+			return nil, fmt.Errorf("sort field relevance cannot be reversed")
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("sort field %s is listed more than once", field)
+		}
+		seen[field] = true
+		sort = append(sort, SortKey{Field: field, Descending: descending})
+	}
+	return sort, nil
+}
+
+// String returns the sort in the syntax ParseSort reads
+func (s Sort) String() string {
+	items := make([]string, len(s))
+	for i, key := range s {
+		items[i] = key.Field
+		if key.Descending {
+			items[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// has reports whether the sort lists a field
+func (s Sort) has(field string) bool {
+	for _, key := range s {
+		if key.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the keys of the sort that order the products of a search
+// for terms; relevance only orders searches, and newest is followed by the
+// creation order of products created in the same second
+func (s Sort) keys(terms []string) Sort {
+	keys := Sort{}
+	for _, key := range s {
+		if key.Field != "relevance" || len(terms) > 0 {
+			keys = append(keys, key)
+		}
+		if key.Field == "newest" {
+			keys = append(keys, SortKey{Field: "creation order", Descending: key.Descending})
+		}
+	}
+	return keys
+}
+
+// field returns the field the key sorts on
+func (k SortKey) field() sortField {
+	if k.Field == "creation order" {
+		return creationOrder
+	}
+	return sortFields[k.Field]
+}
+
+// descending reports whether the key sorts in descending order of the
+// field's expression
+func (k SortKey) descending() bool {
+	return k.Descending != k.field().descending
+}
+
+// orderBy returns the ORDER BY clause of a query for the given backend,
+// with ? placeholders, along with its arguments
+func (q ProductQuery) orderBy(backend string) (string, []interface{}) {
+	terms := searchTerms(q.Search)
+	clauses := []string{}
+	args := []interface{}{}
+	for _, key := range q.Sort.keys(terms) {
+		if key.Field == "relevance" {
+			if backend == "postgres" {
+				clauses = append(clauses, "ts_rank(p.search, to_tsquery('simple', ?)) DESC")
+				args = append(args, tsQuery(terms))
+			} else {
+				// bm25 is lower for better matches
+				clauses = append(clauses, fmt.Sprintf("bm25(products_fts, %g, %g, %g)", searchWeights[0], searchWeights[1], searchWeights[2]))
+			}
+			continue
+		}
+
+		clause := key.field().expression(backend) + " ASC"
+		if key.descending() {
+			clause = key.field().expression(backend) + " DESC"
+		}
+		clauses = append(clauses, clause)
+	}
+	clauses = append(clauses, "p.id ASC")
+	return " ORDER BY " + strings.Join(clauses, ", "), args
+}
+
+// afterCondition returns the condition selecting the products that sort
+// after the cursor of a query for the given backend, with ? placeholders,
+// along with its arguments
+func (q ProductQuery) afterCondition(backend string) (string, []interface{}) {
+	cursor := q.After.product()
+	condition, args := "p.id > ?", []interface{}{cursor.ID}
+
+	// Products come after the cursor on the first key that differs
+	keys := q.Sort.keys(nil)
+	for i := len(keys) - 1; i >= 0; i-- {
+		field := keys[i].field()
+		expression, value := field.expression(backend), field.argument(backend, field.value(cursor))
+		operator := ">"
+		if keys[i].descending() {
+			operator = "<"
+		}
+		condition = fmt.Sprintf("(%s %s ? OR (%s = ? AND %s))", expression, operator, expression, condition)
+		args = append([]interface{}{value, value}, args...)
+	}
+	return condition, args
+}
+
+// expression returns the expression of a field for the given backend
+func (f sortField) expression(backend string) string {
+	if backend == "postgres" {
+		return f.postgres
+	}
+	return f.sqlite
+}
+
+// argument returns a value of the field as a query argument for the given
+// backend. SQLite stores timestamps as text, compared in the format of
+// CURRENT_TIMESTAMP.
+func (f sortField) argument(backend string, value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok && backend == "sqlite" {
+		return t.UTC().Format(sqliteTimestampFormat)
+	}
+	return value
+}
+
+// compare orders two products on the keys of the sort, given the relevance
+// scores of a search by product ID
+func (s Sort) compare(a, b *models.Product, scores map[int]float64) int {
+	for _, key := range s {
+		var c int
+		if key.Field == "relevance" {
+			c = -cmp.Compare(scores[a.ID], scores[b.ID])
+		} else {
+			field := key.field()
+			c = compareValues(field.value(a), field.value(b))
+			if key.descending() {
+				c = -c
+			}
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// compareValues compares two values of a sort field
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("unexpected sort value of type %T", a))
+}
+
+// foldTitle lowercases the ASCII letters of a title, as SQLite's NOCASE
+// collation does
+func foldTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, title)
+}
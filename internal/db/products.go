@@ -1,77 +1,76 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
-// GetProducts retrieves products with filtering, sorting, and pagination
-func GetProducts(page, limit int, category, sort, search string) ([]models.Product, int, error) {
-	// Build the query
-	query := "SELECT id, title, price, category, image FROM products"
-	countQuery := "SELECT COUNT(*) FROM products"
+// ErrProductNotFound is returned when no product exists with the given ID
+var ErrProductNotFound = errors.New("product not found")
+
+// productFilter returns the FROM and WHERE clauses selecting the products
+// matching a query, along with their arguments. Searches go through the
+// full-text index, joined as products_fts.
+func (s *SQLiteStore) productFilter(q ProductQuery) (string, string, []interface{}) {
+	from := " FROM products p"
 	
 	// Add WHERE clauses
-	whereClause := []string{}
-	args := []interface{}{}
-	
-	if category != "" {
-		whereClause = append(whereClause, "category = ?")
-		args = append(args, category)
+	whereClause, args := q.columnConditions()
+	if q.After != nil {
+		condition, afterArgs := q.afterCondition("sqlite")
+		whereClause = append(whereClause, condition)
+		args = append(args, afterArgs...)
 	}
 	
-	if search != "" {
-		whereClause = append(whereClause, "title LIKE ?")
-		args = append(args, "%"+search+"%")
+	if terms := searchTerms(q.Search); len(terms) > 0 {
+		from = " FROM products_fts JOIN products p ON p.id = products_fts.rowid"
+		whereClause = append(whereClause, "products_fts MATCH ?")
+		args = append(args, ftsQuery(terms))
 	}
 	
-	if len(whereClause) > 0 {
-		whereStr := " WHERE " + strings.Join(whereClause, " AND ")
-		query += whereStr
-		countQuery += whereStr
-	}
-	
-	// Add ORDER BY clause
-	if sort != "" {
-		switch sort {
-		case "price_asc":
-			query += " ORDER BY price ASC"
-		case "price_desc":
-			query += " ORDER BY price DESC"
-		default:
-			// Default sort by ID
-			query += " ORDER BY id ASC"
+	for _, name := range q.attributeNames() {
+		values := q.Attributes[name]
+		whereClause = append(whereClause, "EXISTS (SELECT 1 FROM json_each(p.attributes) a WHERE a.key = ? AND a.value IN ("+placeholders(len(values))+"))")
+		args = append(args, name)
+		for _, value := range values {
+			args = append(args, value)
 		}
-	} else {
-		// Default sort by ID
-		query += " ORDER BY id ASC"
 	}
 	
-	// Add LIMIT and OFFSET for pagination
-	if page < 1 {
-		page = 1
+	where := ""
+	if len(whereClause) > 0 {
+		where = " WHERE " + strings.Join(whereClause, " AND ")
 	}
-	if limit < 1 {
-		limit = 10
+	return from, where, args
+}
+
+// GetProducts retrieves products with filtering, sorting, and pagination.
+// Searches match products by word prefixes through the full-text index and
+// return a snippet of each product.
+func (s *SQLiteStore) GetProducts(q ProductQuery) ([]models.Product, error) {
+	q = q.withDefaults()
+	terms := searchTerms(q.Search)
+
+	// Build the query
+	columns := listingColumns
+	if len(terms) > 0 {
+		columns += fmt.Sprintf(", snippet(products_fts, -1, '%s', '%s', '…', %d)", highlightStart, highlightEnd, snippetTokens)
 	}
+	from, where, args := s.productFilter(q)
 	
-	offset := (page - 1) * limit
-	query += " LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
+	// Add ORDER BY clause; products with equal sort keys are kept in ID order
+	orderBy, orderArgs := q.orderBy("sqlite")
+	args = append(args, orderArgs...)
 	
-	// Execute the count query
-	var total int
-	err := DB.QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
+	// Execute the query
+	query := "SELECT " + columns + from + popularityJoin + where + orderBy + " LIMIT ? OFFSET ?"
+	rows, err := s.db.Query(query, append(args, q.Limit, q.Offset)...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error counting products: %w", err)
-	}
-	
-	// Execute the main query
-	rows, err := DB.Query(query, args...)
-	if err != nil {
-		return nil, 0, fmt.Errorf("error querying products: %w", err)
+		return nil, fmt.Errorf("error querying products: %w", err)
 	}
 	defer rows.Close()
 	
@@ -79,38 +78,151 @@ func GetProducts(page, limit int, category, sort, search string) ([]models.Produ
 	products := []models.Product{}
 	for rows.Next() {
 		var product models.Product
-		err := rows.Scan(
-			&product.ID,
-			&product.Title,
-			&product.Price,
-			&product.Category,
-			&product.Image,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("error scanning product: %w", err)
+		dest := productFields(&product)
+		if len(terms) > 0 {
+			dest = append(dest, &product.Snippet)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning product: %w", err)
+		}
+		if len(terms) > 0 {
+			product.Snippet = highlight(product.Snippet)
 		}
 		products = append(products, product)
 	}
 	
 	if err = rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating products: %w", err)
+		return nil, fmt.Errorf("error iterating products: %w", err)
 	}
 	
-	return products, total, nil
+	return products, nil
+}
+
+// CountProducts counts the products matching a query, regardless of its
+// pagination
+func (s *SQLiteStore) CountProducts(q ProductQuery) (int, error) {
+	q.After = nil
+	from, where, args := s.productFilter(q)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*)"+from+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting products: %w", err)
+	}
+	return total, nil
 }
 
 // GetProductByID retrieves a product by ID
-func GetProductByID(id int) (*models.Product, error) {
+func (s *SQLiteStore) GetProductByID(id int) (*models.Product, error) {
 	var product models.Product
-	err := DB.QueryRow("SELECT id, title, price, category, image FROM products WHERE id = ?", id).Scan(
-		&product.ID,
-		&product.Title,
-		&product.Price,
-		&product.Category,
-		&product.Image,
-	)
+	err := s.db.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.id = ?", id).Scan(productFields(&product)...)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
 		return nil, fmt.Errorf("error querying product: %w", err)
 	}
 	return &product, nil
 }
+
+// CreateProduct inserts a new product and returns it with its assigned ID.
+// Its category is created, as a top-level category, if it doesn't exist.
+func (s *SQLiteStore) CreateProduct(product models.Product) (*models.Product, error) {
+	product.Attributes = cloneAttributes(product.Attributes)
+	product.Popularity = 0
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(ensureCategoryQuery, product.Category, product.Category, product.Category); err != nil {
+		return nil, fmt.Errorf("error creating product category: %w", err)
+	}
+
+	// created_at has no default, see migration 0007
+	err = tx.QueryRow(
+		"INSERT INTO products (title, description, price, category, image, stock, rating, attributes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP) RETURNING id, created_at",
+		product.Title, product.Description, product.Price, product.Category, product.Image, product.Stock, product.Rating,
+		attributesJSON(product.Attributes),
+	).Scan(&product.ID, timestampColumn{&product.CreatedAt})
+	if err != nil {
+		return nil, fmt.Errorf("error creating product: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return &product, nil
+}
+
+// UpdateProduct replaces all fields of an existing product. Its category is
+// created, as a top-level category, if it doesn't exist.
+func (s *SQLiteStore) UpdateProduct(product models.Product) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(ensureCategoryQuery, product.Category, product.Category, product.Category); err != nil {
+		return fmt.Errorf("error creating product category: %w", err)
+	}
+
+	result, err := tx.Exec(
+		"UPDATE products SET title = ?, description = ?, price = ?, category = ?, image = ?, stock = ?, rating = ?, attributes = ? WHERE id = ?",
+		product.Title, product.Description, product.Price, product.Category, product.Image, product.Stock, product.Rating,
+		attributesJSON(product.Attributes), product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating product: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrProductNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteProduct deletes a product along with any favorites referencing it
+func (s *SQLiteStore) DeleteProduct(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM favorites WHERE product_id = ?", id); err != nil {
+		return fmt.Errorf("error removing product favorites: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM products WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error deleting product: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrProductNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
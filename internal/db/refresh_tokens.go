@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenNotFound is returned when a refresh token is unknown
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+	// ErrRefreshTokenExpired is returned when a refresh token has expired
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again. The whole token family is revoked when this happens.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// CreateRefreshToken stores the hash of a new refresh token in the given family
+func (s *SQLiteStore) CreateRefreshToken(userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userID, familyID, tokenHash, expiresAt.UTC(),
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("error creating refresh token: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken revokes the refresh token stored under oldHash and stores
+// newHash in the same family, returning the ID of the token's user.
+//
+// If the old token has already been rotated, it is being replayed by someone
+// who should not have it: every token of its family is revoked and
+// ErrRefreshTokenReused is returned along with the user ID.
+func (s *SQLiteStore) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id, userID int
+		familyID   string
+		expires    time.Time
+		revokedAt  sql.NullTime
+	)
+	err = tx.QueryRow(
+		"SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?", oldHash,
+	).Scan(&id, &userID, &familyID, &expires, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying refresh token: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	if revokedAt.Valid {
+		return userID, revokeFamily(tx, familyID, now)
+	}
+
+	if now.After(expires) {
+		return 0, ErrRefreshTokenExpired
+	}
+
+	// Revoke the old token; losing a race against a concurrent rotation of the
+	// same token is treated as reuse as well
+	result, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now, id)
+	if err != nil {
+		return 0, fmt.Errorf("error revoking refresh token: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return userID, revokeFamily(tx, familyID, now)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userID, familyID, newHash, expiresAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error creating refresh token: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// revokeFamily revokes every active token of a family and commits the
+// transaction, returning ErrRefreshTokenReused on success
+func revokeFamily(tx *sql.Tx, familyID string, now time.Time) error {
+	_, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, familyID)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return ErrRefreshTokenReused
+}
+
+// RevokeRefreshTokenFamily revokes the family of the refresh token stored
+// under tokenHash, provided the token belongs to the given user
+func (s *SQLiteStore) RevokeRefreshTokenFamily(userID int, tokenHash string) error {
+	_, err := s.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE revoked_at IS NULL AND family_id IN (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = ? AND user_id = ?
+		)
+	`, time.Now().UTC(), tokenHash, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token of a user
+func (s *SQLiteStore) RevokeUserRefreshTokens(userID int) error {
+	_, err := s.db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredRefreshTokens removes expired refresh tokens, returning how many
+// were removed. Expired tokens are rejected anyway, so their reuse no longer
+// needs to be detected.
+func (s *SQLiteStore) DeleteExpiredRefreshTokens() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM refresh_tokens WHERE expires_at <= ?", time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired refresh tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// RevokeToken records an access token as revoked until it expires
+func (s *SQLiteStore) RevokeToken(jti string, userID int, expiresAt time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT OR IGNORE INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)",
+		jti, userID, expiresAt.UTC(),
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("error revoking token: %w", err)
+	}
+	return nil
+}
+
+// GetRevokedTokens retrieves the IDs and expiry times of all revoked access
+// tokens that have not expired yet
+func (s *SQLiteStore) GetRevokedTokens() (map[string]time.Time, error) {
+	rows, err := s.db.Query("SELECT jti, expires_at FROM revoked_tokens")
+	if err != nil {
+		return nil, fmt.Errorf("error querying revoked tokens: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	revoked := map[string]time.Time{}
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, fmt.Errorf("error scanning revoked token: %w", err)
+		}
+		if expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating revoked tokens: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredRevokedTokens removes revoked access tokens that have expired
+// anyway, returning how many were removed
+func (s *SQLiteStore) DeleteExpiredRevokedTokens() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM revoked_tokens WHERE expires_at <= ?", time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired revoked tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
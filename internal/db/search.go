@@ -0,0 +1,112 @@
+package db
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Products are searched by the words of their title, description and
+// category. Words are runs of letters and digits, compared regardless of case;
+// every word of a search must be the prefix of a word of the product.
+//
+// SQLite ranks matches with FTS5's bm25 function, weighing words of the title
+// and the category above those of the description, and Postgres with ts_rank
+// and the same order of weights. The memory store emulates FTS5, see fts5.go.
+
+// searchWeights are the bm25 weights of the title, description and category
+var searchWeights = [3]float64{5, 1, 2}
+
+// snippetTokens is the maximum number of words in a snippet
+const snippetTokens = 12
+
+// Markers the backends put around matched words in snippets, turned into
+// <mark> elements by highlight. They are Unicode noncharacters, which have no
+// place in product text.
+const (
+	highlightStart = "\ufdd0"
+	highlightEnd   = "\ufdd1"
+)
+
+// isWordRune reports whether r is part of a word, like the unicode61
+// tokenizer of SQLite's FTS5
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Co, r)
+}
+
+// wordSpan is the position of a word in a text
+type wordSpan struct {
+	start, end int
+	word       string // Lowercased
+}
+
+// words returns the words of text in order
+func words(text string) []wordSpan {
+	spans := []wordSpan{}
+	start := -1
+	for i, r := range text {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			spans = append(spans, wordSpan{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start, len(text), strings.ToLower(text[start:])})
+	}
+	return spans
+}
+
+// searchTerms returns the distinct words of a search. A search without words
+// matches every product.
+func searchTerms(search string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, span := range words(search) {
+		if !seen[span.word] {
+			seen[span.word] = true
+			terms = append(terms, span.word)
+		}
+	}
+	return terms
+}
+
+// matchesTerm reports whether word starts with one of the terms
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// ftsQuery returns the FTS5 query matching products with every term as a
+// word prefix. Terms are quoted so that words such as AND are not operators.
+func ftsQuery(terms []string) string {
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + term + `"*`
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// tsQuery returns the Postgres text search query matching products with every
+// term as a word prefix
+func tsQuery(terms []string) string {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+	return strings.Join(prefixes, " & ")
+}
+
+// highlight HTML-escapes a snippet and turns its highlight markers into
+// <mark> elements
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightEnd, "</mark>")
+}
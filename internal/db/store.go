@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// ProductStore stores the product catalog
+type ProductStore interface {
+	// GetProducts retrieves a page of products matching the query
+	GetProducts(query ProductQuery) ([]models.Product, error)
+	// CountProducts counts every product matching the query
+	CountProducts(query ProductQuery) (int, error)
+	// CountProductsByCategory counts the products matching the query in each
+	// category that has any
+	CountProductsByCategory(query ProductQuery) ([]models.CategoryCount, error)
+	// CountProductsByPrice counts the products matching the query in each
+	// price range
+	CountProductsByPrice(query ProductQuery) ([]models.PriceRange, error)
+	GetProductByID(id int) (*models.Product, error)
+	CreateProduct(product models.Product) (*models.Product, error)
+	UpdateProduct(product models.Product) error
+	DeleteProduct(id int) error
+}
+
+// CategoryStore stores the category tree of the catalog, which products refer
+// to by slug
+type CategoryStore interface {
+	// GetCategories retrieves every category along with its product count
+	GetCategories() ([]models.Category, error)
+	GetCategory(slug string) (*models.Category, error)
+	CreateCategory(category models.Category) (*models.Category, error)
+	// UpdateCategory renames and moves the category with the given slug
+	UpdateCategory(category models.Category) error
+	DeleteCategory(slug string) error
+}
+
+// UserStore stores user accounts. Passwords are given in plaintext and stored
+// hashed with the password hasher of the store's options.
+type UserStore interface {
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	CreateUser(username, plaintext, role string) (*models.User, error)
+	SetUserRole(id int, role string) error
+	UpdatePassword(id int, plaintext string) error
+	// ValidatePassword validates a password against a stored hash;
+	// needsRehash reports whether a matching hash should be replaced using
+	// UpdatePassword, as it was produced by another hasher or parameters
+	ValidatePassword(plaintext, hash string) (ok, needsRehash bool)
+	// ValidateDummyPassword validates a password against a hash produced by
+	// the store's hasher that no user has, taking as long as ValidatePassword
+	// so that failed logins don't reveal whether the username exists
+	ValidateDummyPassword(plaintext string)
+	GetTokenVersion(id int) (int, error)
+	IncrementTokenVersion(id int) (int, error)
+}
+
+// FavoriteStore stores the products favorited by each user
+type FavoriteStore interface {
+	AddFavorite(userID, productID int, notes string) error
+	GetFavorites(userID, page, limit int) ([]models.FavoriteProduct, int, error)
+	RemoveFavorite(userID, productID int) error
+}
+
+// TokenStore stores refresh tokens and revoked access tokens
+type TokenStore interface {
+	CreateRefreshToken(userID int, familyID, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error)
+	RevokeRefreshTokenFamily(userID int, tokenHash string) error
+	RevokeUserRefreshTokens(userID int) error
+	DeleteExpiredRefreshTokens() (int64, error)
+	RevokeToken(jti string, userID int, expiresAt time.Time) error
+	GetRevokedTokens() (map[string]time.Time, error)
+	DeleteExpiredRevokedTokens() (int64, error)
+}
+
+// Store combines all stores of a single backend
+type Store interface {
+	ProductStore
+	CategoryStore
+	UserStore
+	FavoriteStore
+	TokenStore
+	Close() error
+}
+
+// SQLiteStore implements every store
+var _ Store = (*SQLiteStore)(nil)
+
+// Open opens the store of the given driver: "sqlite" for the SQLite database
+// at the path given as source, "postgres" for the Postgres database given by
+// the DSN in source, or "memory" for an empty in-memory store
+func Open(driver, source string, options Options) (Store, error) {
+	switch driver {
+	case "sqlite":
+		return OpenSQLite(source, options)
+	case "postgres":
+		return OpenPostgres(source, options)
+	case "memory":
+		return NewMemoryStore(options), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+}
@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	migrator, err := db.OpenMigrator("sqlite", dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer migrator.Close()
+
+	// A new database has every migration pending
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Error getting status: %v", err)
+	}
+	if len(statuses) == 0 || statuses[0].Version != 1 {
+		t.Fatalf("Expected migrations starting at version 1, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("Expected migration %d to be pending", status.Version)
+		}
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(statuses) {
+		t.Fatalf("Expected %d migrations applied, got %d, %v", len(statuses), len(applied), err)
+	}
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing left to apply, got %d, %v", len(applied), err)
+	}
+	statuses, _ = migrator.Status()
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+
+	// Reverting everything drops the tables, and migrating again recreates them
+	reverted, err := migrator.Down(len(statuses))
+	if err != nil || len(reverted) != len(statuses) || reverted[0].Version != len(statuses) {
+		t.Fatalf("Expected every migration reverted, latest first, got %+v, %v", reverted, err)
+	}
+	if reverted, err := migrator.Down(1); err != nil || len(reverted) != 0 {
+		t.Errorf("Expected nothing left to revert, got %d, %v", len(reverted), err)
+	}
+
+	store, err := db.OpenSQLite(dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.CreateUser("alice", "secret123", models.RoleUser); err != nil {
+		t.Errorf("Expected a usable store after migrating again, got %v", err)
+	}
+}
+
+func TestMigrationsRefuseUnknownSchema(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		query         string
+		expectedError error
+	}{
+		{
+			name:          "Newer schema",
+			query:         "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (999, 'future', '', CURRENT_TIMESTAMP)",
+			expectedError: db.ErrSchemaTooNew,
+		},
+		{
+			name:          "Modified migration",
+			query:         "UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1",
+			expectedError: db.ErrMigrationModified,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dbPath := filepath.Join(t.TempDir(), "test.db")
+			store, err := db.OpenSQLite(dbPath, testOptions)
+			if err != nil {
+				t.Fatalf("Error opening database: %v", err)
+			}
+			store.Close()
+
+			conn, err := sql.Open("sqlite", dbPath)
+			if err != nil {
+				t.Fatalf("Error opening database: %v", err)
+			}
+			defer conn.Close()
+			if _, err := conn.Exec(tc.query); err != nil {
+				t.Fatalf("Error changing schema_migrations: %v", err)
+			}
+
+			if _, err := db.OpenSQLite(dbPath, testOptions); !errors.Is(err, tc.expectedError) {
+				t.Errorf("Expected %v when opening the store, got %v", tc.expectedError, err)
+			}
+
+			migrator, err := db.OpenMigrator("sqlite", dbPath, testOptions)
+			if err != nil {
+				t.Fatalf("Error opening database: %v", err)
+			}
+			defer migrator.Close()
+			if _, err := migrator.Up(); !errors.Is(err, tc.expectedError) {
+				t.Errorf("Expected %v when migrating up, got %v", tc.expectedError, err)
+			}
+			if _, err := migrator.Down(1); !errors.Is(err, tc.expectedError) {
+				t.Errorf("Expected %v when migrating down, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+// TestLegacySchemaUpgrade opens a database created before roles, token
+// versions, favorite timestamps, product search, categories and migrations
+// existed
+func TestLegacySchemaUpgrade(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	createLegacySchema(t, dbPath, "hash")
+
+	store, err := db.OpenSQLite(dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening legacy database: %v", err)
+	}
+	defer store.Close()
+
+	user, err := store.GetUserByUsername("alice")
+	if err != nil || user.Role != models.RoleUser || user.TokenVersion != 0 {
+		t.Errorf("Expected alice with the default role, got %+v, %v", user, err)
+	}
+	favorites, _, err := store.GetFavorites(1, 1, 10)
+	if err != nil || len(favorites) != 1 || favorites[0].Notes != "nice" || favorites[0].CreatedAt.IsZero() {
+		t.Errorf("Expected the favorite with backfilled timestamps, got %+v, %v", favorites, err)
+	}
+	if err := store.RevokeToken("jti", user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("Expected the tables added later to exist, got %v", err)
+	}
+	products, err := store.GetProducts(db.ProductQuery{Search: "lamp"})
+	if err != nil || len(products) != 1 || products[0].Description != "" || len(products[0].Attributes) != 0 {
+		t.Errorf("Expected the existing product to be indexed for search, got %+v, %v", products, err)
+	}
+	categories, err := store.GetCategories()
+	if err != nil || len(categories) != 1 || categories[0].Slug != "home" || categories[0].ProductCount != 1 {
+		t.Errorf("Expected the category of the existing product to be created, got %+v, %v", categories, err)
+	}
+}
+
+// TestMigratorUpgradesLegacySchema migrates a database created before
+// migrations existed with the migrator rather than by opening the store, and
+// logs in as its existing user
+func TestMigratorUpgradesLegacySchema(t *testing.T) {
+	t.Parallel()
+
+	hash, err := testOptions.Hasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Error hashing password: %v", err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	createLegacySchema(t, dbPath, hash)
+
+	migrator, err := db.OpenMigrator("sqlite", dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Error migrating legacy database: %v", err)
+	}
+	migrator.Close()
+
+	store, err := db.OpenSQLite(dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening migrated database: %v", err)
+	}
+	defer store.Close()
+
+	user, err := store.GetUserByUsername("alice")
+	if err != nil || user.Role != models.RoleUser {
+		t.Fatalf("Expected alice with the default role, got %+v, %v", user, err)
+	}
+	if ok, _ := store.ValidatePassword("secret", user.Password); !ok {
+		t.Errorf("Expected alice to log in with their password")
+	}
+	favorites, _, err := store.GetFavorites(user.ID, 1, 10)
+	if err != nil || len(favorites) != 1 || favorites[0].CreatedAt.IsZero() {
+		t.Errorf("Expected the favorite with backfilled timestamps, got %+v, %v", favorites, err)
+	}
+}
+
+// createLegacySchema creates a database as it was before roles, token
+// versions, favorite timestamps, product search, categories and migrations
+// existed, with a user alice having the given password hash, a product and
+// a favorite
+func createLegacySchema(t *testing.T, dbPath, passwordHash string) {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer conn.Close()
+	_, err = conn.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE NOT NULL, password TEXT NOT NULL);
+		CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, price REAL NOT NULL, category TEXT NOT NULL, image TEXT NOT NULL);
+		CREATE TABLE favorites (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL, product_id INTEGER NOT NULL, notes TEXT,
+			FOREIGN KEY (user_id) REFERENCES users (id), FOREIGN KEY (product_id) REFERENCES products (id), UNIQUE (user_id, product_id)
+		);
+		INSERT INTO products (title, price, category, image) VALUES ('Lamp', 25, 'home', 'https://example.com/lamp.jpg');
+	`)
+	if err != nil {
+		t.Fatalf("Error creating legacy schema: %v", err)
+	}
+	_, err = conn.Exec("INSERT INTO users (username, password) VALUES ('alice', ?)", passwordHash)
+	if err != nil {
+		t.Fatalf("Error creating legacy user: %v", err)
+	}
+	if _, err = conn.Exec("INSERT INTO favorites (user_id, product_id, notes) VALUES (1, 1, 'nice')"); err != nil {
+		t.Fatalf("Error creating legacy favorite: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+)
+
+// startPostgres returns the DSN of the Postgres server to test against: the
+// one in TEST_POSTGRES_DSN, or a throwaway cluster started from the local
+// Postgres binaries, which stop shuts down and removes
+func startPostgres() (dsn string, stop func(), err error) {
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		return dsn, func() {}, nil
+	}
+
+	bin, err := postgresBinDir()
+	if err != nil {
+		return "", nil, err
+	}
+	if os.Geteuid() == 0 {
+		return "", nil, errors.New("initdb cannot run as root; set TEST_POSTGRES_DSN instead")
+	}
+
+	dir, err := os.MkdirTemp("", "catalog-postgres-")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	// Create the cluster, listening on a unix socket in dir only
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "--no-sync")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb failed: %v: %s", err, output)
+	}
+	start := exec.Command(filepath.Join(bin, "pg_ctl"), "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-w",
+		"-o", fmt.Sprintf("-k %s -c listen_addresses='' -c fsync=off", dir), "start")
+	if output, err := start.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("pg_ctl start failed: %v: %s", err, output)
+	}
+
+	stop = func() {
+		exec.Command(filepath.Join(bin, "pg_ctl"), "-D", data, "-m", "immediate", "stop").Run()
+		os.RemoveAll(dir)
+	}
+	return fmt.Sprintf("host=%s user=postgres dbname=postgres sslmode=disable", dir), stop, nil
+}
+
+// postgresBinDir finds the directory holding initdb and pg_ctl
+func postgresBinDir() (string, error) {
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path), nil
+	}
+
+	// Debian and Ubuntu keep the server binaries out of PATH, and pg_config
+	// may come without them
+	var candidates []string
+	if output, err := exec.Command("pg_config", "--bindir").Output(); err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(output)))
+	}
+	dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range append(candidates, dirs...) {
+		if _, err := os.Stat(filepath.Join(dir, "initdb")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", errors.New("no Postgres binaries found; install Postgres or set TEST_POSTGRES_DSN")
+}
+
+// databases numbers the databases created by openPostgres
+var databases atomic.Int64
+
+// openPostgres returns a backend opening a store on a fresh database of the
+// server at dsn, which is dropped when the test ends
+func openPostgres(dsn string) func(t *testing.T) db.Store {
+	return func(t *testing.T) db.Store {
+		admin, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Fatalf("Error connecting to Postgres: %v", err)
+		}
+		t.Cleanup(func() { admin.Close() })
+
+		name := fmt.Sprintf("catalog_test_%d_%d", os.Getpid(), databases.Add(1))
+		if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+			t.Fatalf("Error creating database: %v", err)
+		}
+
+		store, err := db.OpenPostgres(withDatabase(dsn, name), testOptions)
+		if err != nil {
+			t.Fatalf("Error opening database: %v", err)
+		}
+		t.Cleanup(func() {
+			store.Close()
+			admin.Exec("DROP DATABASE " + name)
+		})
+		return store
+	}
+}
+
+// withDatabase returns dsn with its database replaced by name
+func withDatabase(dsn, name string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		u.Path = "/" + name
+		return u.String()
+	}
+	// Later keys take precedence in key/value DSNs
+	return dsn + " dbname=" + name
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// TestSearchMatchesFTS5 pins the results of SQLite's FTS5 on a fixed corpus,
+// which the memory store's port of its bm25 and snippet functions must
+// reproduce. Postgres ranks with ts_rank, so only its snippets are expected
+// to match, which the store conformance tests check.
+func TestSearchMatchesFTS5(t *testing.T) {
+	t.Parallel()
+
+	corpus := []models.Product{
+		{Title: "Oak desk", Category: "furniture", Description: "A writing desk with two drawers. The top is solid oak, the legs are steel."},
+		{Title: "Desk lamp", Category: "lighting", Description: "Adjustable arm and a weighted base. Fits on any desk: the cable runs inside the arm, " +
+			"and the shade tilts to light the page without glare on the screen."},
+		{Title: "Walnut shelf", Category: "furniture", Description: "Wall mounted shelf in walnut with hidden steel brackets, walnut veneer on the front " +
+			"and oiled walnut edges."},
+		{Title: "Bookcase", Category: "furniture", Description: "Five shelves of oak veneer on a particle board frame, with adjustable shelf pins so " +
+			"that every shelf can be moved. Assembly takes an hour and needs two people; the drawer at the bottom is oak."},
+		{Title: "Cable tray", Category: "office", Description: "Steel tray mounted under the desk top to keep cables off the floor."},
+		{Title: "Ähnlich lamp", Category: "lighting", Description: "Floor lamp with a linen shade and a brass finish. Light is warm and dimmable."},
+		{Title: "Oak", Category: "wood", Description: "Sample of oak wood finish."},
+		{Title: "Steel drawer unit", Category: "office", Description: "Three drawers on castors that fit under most desks; the top drawer locks."},
+		{Title: "Bench", Category: "furniture", Description: "Reclaimed boards from old barns are planed smooth and joined by hand into a sturdy " +
+			"bench with a timber seat that fits three adults comfortably around any table"},
+		{Title: "Coat rack", Category: "hallway", Description: "Plated hooks for coats, hats and scarves, mounted by the door of any busy " +
+			"family home. The rail is made of brushed zinc and comes with four screws for the wall. Both end caps are zinc and the " +
+			"hooks are plated with zinc as well."},
+		{Title: "Mug", Category: "kitchen", Description: "Hand thrown mug with a glossy copper glaze and a wide handle. Each mug is fired " +
+			"twice, and the copper tones vary from piece to piece."},
+		{Title: "Cushion", Category: "textiles", Description: "Linen cover."},
+		{Title: "Duvet cover", Category: "textiles", Description: "Linen on the front, linen on the back and linen piping around the edges, " +
+			"stuffed with feathers and stitched by hand"},
+		{Title: "Throw", Category: "textiles", Description: "Soft wool blanket."},
+		{Title: "Sweater", Category: "clothing", Description: "Knitted by hand from undyed wool in a cable pattern, with ribbed cuffs, a " +
+			"crew neck and a relaxed fit that layers over shirts; the wool is spun in small batches by a family mill in the hills " +
+			"and washed without harsh chemicals"},
+	}
+
+	testCases := []struct {
+		name        string
+		search      string
+		expectedIDs []int
+		snippets    []string
+	}{
+		{name: "Title and description above description", search: "oak", expectedIDs: []int{7, 1, 4}, snippets: []string{
+			"<mark>Oak</mark>",
+			"…The top is solid <mark>oak</mark>, the legs are steel.",
+			"Five shelves of <mark>oak</mark> veneer on a particle board frame, with adjustable…",
+		}},
+		{name: "Repeated matches in one window", search: "walnut", expectedIDs: []int{3}, snippets: []string{
+			"Wall mounted shelf in <mark>walnut</mark> with hidden steel brackets, <mark>walnut</mark> veneer on…",
+		}},
+		{name: "Prefix matches", search: "drawer", expectedIDs: []int{8, 1, 4}, snippets: []string{
+			"Three <mark>drawers</mark> on castors that fit under most desks; the top <mark>drawer</mark>…",
+			"A writing desk with two <mark>drawers</mark>. The top is solid oak, the…",
+			"…Assembly takes an hour and needs two people; the <mark>drawer</mark> at the…",
+		}},
+		{name: "Window after a colon", search: "cable", expectedIDs: []int{5, 2, 15}, snippets: []string{
+			"Steel tray mounted under the desk top to keep <mark>cables</mark> off the…",
+			"…the <mark>cable</mark> runs inside the arm, and the shade tilts to light…",
+			"Knitted by hand from undyed wool in a <mark>cable</mark> pattern, with ribbed…",
+		}},
+		{name: "Window holding every term", search: "oak drawer", expectedIDs: []int{1, 4}, snippets: []string{
+			"A writing desk with two <mark>drawers</mark>. The top is solid <mark>oak</mark>, the…",
+			"…hour and needs two people; the <mark>drawer</mark> at the bottom is <mark>oak</mark>.",
+		}},
+		{name: "Order of terms", search: "drawer oak", expectedIDs: []int{1, 4}, snippets: []string{
+			"A writing desk with two <mark>drawers</mark>. The top is solid <mark>oak</mark>, the…",
+			"…hour and needs two people; the <mark>drawer</mark> at the bottom is <mark>oak</mark>.",
+		}},
+		{name: "Title above descriptions", search: "steel", expectedIDs: []int{8, 5, 1, 3}, snippets: []string{
+			"<mark>Steel</mark> drawer unit",
+			"<mark>Steel</mark> tray mounted under the desk top to keep cables off the…",
+			"…The top is solid oak, the legs are <mark>steel</mark>.",
+			"Wall mounted shelf in walnut with hidden <mark>steel</mark> brackets, walnut veneer on…",
+		}},
+		{name: "Window at the end of a column", search: "light", expectedIDs: []int{6, 2}, snippets: []string{
+			"…a linen shade and a brass finish. <mark>Light</mark> is warm and dimmable.",
+			"…the cable runs inside the arm, and the shade tilts to <mark>light</mark>…",
+		}},
+		{name: "Best column of the snippet", search: "lamp", expectedIDs: []int{6, 2}, snippets: []string{
+			"Floor <mark>lamp</mark> with a linen shade and a brass finish. Light is…",
+			"Desk <mark>lamp</mark>",
+		}},
+		{name: "Several matches in a long column", search: "shelf", expectedIDs: []int{3, 4}, snippets: []string{
+			"Wall mounted <mark>shelf</mark> in walnut with hidden steel brackets, walnut veneer on…",
+			"…frame, with adjustable <mark>shelf</mark> pins so that every <mark>shelf</mark> can be moved…",
+		}},
+		{name: "Letters beyond ASCII", search: "ähn", expectedIDs: []int{6}, snippets: []string{"<mark>Ähnlich</mark> lamp"}},
+		{name: "Shorter documents rank higher", search: "finish", expectedIDs: []int{7, 6}, snippets: []string{
+			"Sample of oak wood <mark>finish</mark>.",
+			"Floor lamp with a linen shade and a brass <mark>finish</mark>. Light is…",
+		}},
+		{name: "Window at a sentence", search: "legs", expectedIDs: []int{1}, snippets: []string{
+			"…The top is solid oak, the <mark>legs</mark> are steel.",
+		}},
+		{name: "Description matches by length", search: "top", expectedIDs: []int{5, 8, 1}, snippets: []string{
+			"Steel tray mounted under the desk <mark>top</mark> to keep cables off the…",
+			"Three drawers on castors that fit under most desks; the <mark>top</mark> drawer…",
+			"…The <mark>top</mark> is solid oak, the legs are steel.",
+		}},
+		{name: "Window centered on the match", search: "timber", expectedIDs: []int{9}, snippets: []string{
+			"…a sturdy bench with a <mark>timber</mark> seat that fits three adults comfortably…",
+		}},
+		{name: "Sentence with repeated matches", search: "zinc", expectedIDs: []int{10}, snippets: []string{
+			"…Both end caps are <mark>zinc</mark> and the hooks are plated with <mark>zinc</mark>…",
+		}},
+		{name: "First sentence preferred", search: "copper", expectedIDs: []int{11}, snippets: []string{
+			"Hand thrown mug with a glossy <mark>copper</mark> glaze and a wide handle…",
+		}},
+		{name: "Term frequency", search: "linen", expectedIDs: []int{13, 12, 6}, snippets: []string{
+			"<mark>Linen</mark> on the front, <mark>linen</mark> on the back and <mark>linen</mark> piping around…",
+			"<mark>Linen</mark> cover.",
+			"Floor lamp with a <mark>linen</mark> shade and a brass finish. Light is…",
+		}},
+		{name: "Common terms barely count", search: "the oak", expectedIDs: []int{1, 4}, snippets: []string{
+			"…<mark>The</mark> top is solid <mark>oak</mark>, <mark>the</mark> legs are steel.",
+			"…hour and needs two people; <mark>the</mark> drawer at <mark>the</mark> bottom is <mark>oak</mark>.",
+		}},
+		{name: "Common terms highlighted", search: "the desk", expectedIDs: []int{1, 2, 5, 8}, snippets: []string{
+			"A writing <mark>desk</mark> with two drawers. <mark>The</mark> top is solid oak, <mark>the</mark>…",
+			"…Fits on any <mark>desk</mark>: <mark>the</mark> cable runs inside <mark>the</mark> arm, and <mark>the</mark>…",
+			"Steel tray mounted under <mark>the</mark> <mark>desk</mark> top to keep cables off <mark>the</mark>…",
+			"Three drawers on castors that fit under most <mark>desks</mark>; <mark>the</mark> top drawer…",
+		}},
+		{name: "Long documents rank lower", search: "wool", expectedIDs: []int{14, 15}, snippets: []string{
+			"Soft <mark>wool</mark> blanket.",
+			"Knitted by hand from undyed <mark>wool</mark> in a cable pattern, with ribbed…",
+		}},
+		{name: "Category and description", search: "wood", expectedIDs: []int{7}, snippets: []string{
+			"Sample of oak <mark>wood</mark> finish.",
+		}},
+	}
+
+	for _, backend := range []string{"sqlite", "memory"} {
+		store := backends[backend](t)
+		for _, product := range corpus {
+			product.Price = 10
+			product.Image = "https://example.com/image.jpg"
+			if _, err := store.CreateProduct(product); err != nil {
+				t.Fatalf("%s: error creating product: %v", backend, err)
+			}
+		}
+
+		t.Run(backend, func(t *testing.T) {
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					results, err := store.GetProducts(db.ProductQuery{Search: tc.search, Sort: sortBy("relevance")})
+					if err != nil {
+						t.Fatalf("Error getting products: %v", err)
+					}
+					if ids := productIDs(results); !reflect.DeepEqual(ids, tc.expectedIDs) {
+						t.Errorf("Expected products %v, got %v", tc.expectedIDs, ids)
+					}
+					snippets := []string{}
+					for _, product := range results {
+						snippets = append(snippets, product.Snippet)
+					}
+					if !reflect.DeepEqual(snippets, tc.snippets) {
+						t.Errorf("Expected snippets %q, got %q", tc.snippets, snippets)
+					}
+				})
+			}
+		})
+	}
+}
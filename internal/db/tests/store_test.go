@@ -0,0 +1,1100 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/password"
+)
+
+// testOptions opens stores with a cheap password hasher; hashing cost is
+// irrelevant here and dominates the run time otherwise
+var testOptions = db.Options{Hasher: password.NewBcrypt(4)}
+
+// backends opens an empty store of each backend
+var backends = map[string]func(t *testing.T) db.Store{
+	"sqlite": func(t *testing.T) db.Store {
+		store, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"), testOptions)
+		if err != nil {
+			t.Fatalf("Error opening database: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	},
+	"memory": func(t *testing.T) db.Store {
+		return db.NewMemoryStore(testOptions)
+	},
+}
+
+// unavailable holds why backends can't be tested here, by backend
+var unavailable = map[string]string{}
+
+func TestMain(m *testing.M) {
+	// Test Postgres as well when a server is available
+	dsn, stop, err := startPostgres()
+	if err != nil {
+		// CI sets TEST_POSTGRES_REQUIRED so that Postgres can't go untested
+		if os.Getenv("TEST_POSTGRES_REQUIRED") != "" {
+			fmt.Fprintf(os.Stderr, "Postgres is required but unavailable: %v\n", err)
+			os.Exit(1)
+		}
+		unavailable["postgres"] = err.Error()
+		os.Exit(m.Run())
+	}
+	backends["postgres"] = openPostgres(dsn)
+
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// skipUnavailable reports every backend that can't be tested here as a
+// skipped subtest of t
+func skipUnavailable(t *testing.T) {
+	for backend, reason := range unavailable {
+		t.Run(backend, func(t *testing.T) {
+			t.Skip(reason)
+		})
+	}
+}
+
+// TestStoreConformance runs the same tests against every backend
+func TestStoreConformance(t *testing.T) {
+	skipUnavailable(t)
+
+	tests := map[string]func(t *testing.T, store db.Store){
+		"Users":                testUsers,
+		"Products":             testProducts,
+		"ProductQueries":       testProductQueries,
+		"SearchRanking":        testSearchRanking,
+		"Categories":           testCategories,
+		"Favorites":            testFavorites,
+		"RefreshTokens":        testRefreshTokens,
+		"RevokedTokens":        testRevokedTokens,
+		"DeleteProductCascade": testDeleteProductCascade,
+	}
+
+	for backend, open := range backends {
+		t.Run(backend, func(t *testing.T) {
+			t.Parallel()
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+					test(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+func testUsers(t *testing.T, store db.Store) {
+	user, err := store.CreateUser("alice", "secret123", models.RoleEditor)
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	if user.ID != 1 || user.Username != "alice" || user.Role != models.RoleEditor || user.TokenVersion != 0 {
+		t.Errorf("Unexpected created user: %+v", user)
+	}
+
+	if _, err := store.CreateUser("alice", "other123", models.RoleUser); !errors.Is(err, db.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if _, err := store.CreateUser("Alice", "other123", models.RoleUser); err != nil {
+		t.Errorf("Usernames should be case-sensitive, got %v", err)
+	}
+	if _, err := store.CreateUser("bob", "secret123", "root"); err == nil {
+		t.Errorf("Expected an error for an invalid role")
+	}
+
+	byName, err := store.GetUserByUsername("alice")
+	if err != nil || byName.ID != user.ID {
+		t.Fatalf("Expected user %d by username, got %+v, %v", user.ID, byName, err)
+	}
+	// The password is hashed with the hasher of the options
+	if ok, needsRehash := store.ValidatePassword("secret123", byName.Password); !ok || needsRehash {
+		t.Errorf("Expected the stored hash to match the password, got ok=%v needsRehash=%v", ok, needsRehash)
+	}
+	if _, err := store.GetUserByUsername("nobody"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := store.GetUserByID(999); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	// Roles and passwords can be changed
+	if err := store.SetUserRole(user.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("Error setting role: %v", err)
+	}
+	if err := store.UpdatePassword(user.ID, "changed123"); err != nil {
+		t.Fatalf("Error updating password: %v", err)
+	}
+	byID, err := store.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("Error getting user: %v", err)
+	}
+	if byID.Role != models.RoleAdmin {
+		t.Errorf("Expected role %q, got %q", models.RoleAdmin, byID.Role)
+	}
+	if ok, _ := store.ValidatePassword("changed123", byID.Password); !ok {
+		t.Errorf("Expected the updated password to match")
+	}
+	if err := store.SetUserRole(999, models.RoleAdmin); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if err := store.UpdatePassword(999, "changed123"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	// Token versions start at 0 and only go up
+	for want := 1; want <= 2; want++ {
+		version, err := store.IncrementTokenVersion(user.ID)
+		if err != nil || version != want {
+			t.Errorf("Expected token version %d, got %d, %v", want, version, err)
+		}
+	}
+	if version, err := store.GetTokenVersion(user.ID); err != nil || version != 2 {
+		t.Errorf("Expected token version 2, got %d, %v", version, err)
+	}
+	if _, err := store.GetTokenVersion(999); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := store.IncrementTokenVersion(999); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func testProducts(t *testing.T, store db.Store) {
+	created, err := store.CreateProduct(models.Product{Title: "Lamp", Price: 25, Category: "home", Image: "https://example.com/lamp.jpg"})
+	if err != nil {
+		t.Fatalf("Error creating product: %v", err)
+	}
+	if created.ID != 1 {
+		t.Errorf("Expected ID 1, got %d", created.ID)
+	}
+
+	product, err := store.GetProductByID(created.ID)
+	if err != nil || !reflect.DeepEqual(product, created) {
+		t.Fatalf("Expected %+v, got %+v, %v", created, product, err)
+	}
+
+	updated := *created
+	updated.Title = "Desk Lamp"
+	updated.Price = 30.5
+	updated.Stock = 3
+	updated.Attributes = map[string]string{"color": "black"}
+	if err := store.UpdateProduct(updated); err != nil {
+		t.Fatalf("Error updating product: %v", err)
+	}
+	if product, _ := store.GetProductByID(created.ID); !reflect.DeepEqual(*product, updated) {
+		t.Errorf("Expected %+v, got %+v", updated, product)
+	}
+
+	// Searches follow updates
+	if results, _ := store.GetProducts(db.ProductQuery{Search: "desk"}); len(results) != 1 || results[0].ID != created.ID {
+		t.Errorf("Expected the updated product to be found, got %+v", results)
+	}
+
+	missing := updated
+	missing.ID = 999
+	if err := store.UpdateProduct(missing); !errors.Is(err, db.ErrProductNotFound) {
+		t.Errorf("Expected ErrProductNotFound, got %v", err)
+	}
+
+	if err := store.DeleteProduct(created.ID); err != nil {
+		t.Fatalf("Error deleting product: %v", err)
+	}
+	if _, err := store.GetProductByID(created.ID); !errors.Is(err, db.ErrProductNotFound) {
+		t.Errorf("Expected ErrProductNotFound, got %v", err)
+	}
+	if err := store.DeleteProduct(created.ID); !errors.Is(err, db.ErrProductNotFound) {
+		t.Errorf("Expected ErrProductNotFound, got %v", err)
+	}
+	if results, _ := store.GetProducts(db.ProductQuery{Search: "desk"}); len(results) != 0 {
+		t.Errorf("Expected the deleted product not to be found, got %+v", results)
+	}
+
+	// IDs of deleted products are not reused
+	next, err := store.CreateProduct(models.Product{Title: "Chair", Price: 80, Category: "home", Image: "https://example.com/chair.jpg"})
+	if err != nil || next.ID != 2 {
+		t.Errorf("Expected ID 2, got %+v, %v", next, err)
+	}
+}
+
+func testProductQueries(t *testing.T, store db.Store) {
+	products := []models.Product{
+		{Title: "Smartphone", Description: "Comes with a case", Price: 499.99, Category: "electronics", Stock: 5, Rating: 4.5,
+			Attributes: map[string]string{"color": "black", "storage": "128GB"}},
+		{Title: "Laptop", Description: "Thin and light notebook", Price: 999.99, Category: "electronics", Rating: 4.5,
+			Attributes: map[string]string{"color": "silver"}},
+		{Title: "T-Shirt", Price: 19.99, Category: "clothing", Stock: 12, Rating: 3, Attributes: map[string]string{"color": "black", "size": "M"}},
+		{Title: "Phone Case", Price: 19.99, Category: "accessories", Stock: 3, Attributes: map[string]string{"color": "red"}},
+		{Title: "100% Cotton Shirt", Price: 29.99, Category: "clothing", Attributes: map[string]string{"size": "L"}},
+		{Title: "Headphones", Description: "Noise <cancelling> & wireless", Price: 499.99, Category: "Electronics", Stock: 1, Rating: 5},
+		{Title: "Ähnlich", Price: 5, Category: "misc"},
+	}
+	for _, product := range products {
+		product.Image = "https://example.com/image.jpg"
+		if _, err := store.CreateProduct(product); err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+	}
+
+	// The laptop is the favorite of two users, the cotton shirt of one
+	createUsers(t, store, 2)
+	for _, favorite := range [][2]int{{1, 2}, {2, 2}, {1, 5}} {
+		if err := store.AddFavorite(favorite[0], favorite[1], ""); err != nil {
+			t.Fatalf("Error adding favorite: %v", err)
+		}
+	}
+	if laptop, err := store.GetProductByID(2); err != nil || laptop.Popularity != 2 {
+		t.Errorf("Expected the laptop to be favorited twice, got %+v, %v", laptop, err)
+	}
+
+	testCases := []struct {
+		name          string
+		query         db.ProductQuery
+		expectedIDs   []int
+		expectedTotal int
+		snippets      []string
+	}{
+		{name: "All products", query: db.ProductQuery{Limit: 10}, expectedIDs: []int{1, 2, 3, 4, 5, 6, 7}, expectedTotal: 7},
+		{name: "Default page and limit", query: db.ProductQuery{}, expectedIDs: []int{1, 2, 3, 4, 5, 6, 7}, expectedTotal: 7},
+		{name: "Category is case-sensitive", query: db.ProductQuery{Categories: []string{"electronics"}}, expectedIDs: []int{1, 2}, expectedTotal: 2},
+		{name: "Unknown category", query: db.ProductQuery{Categories: []string{"garden"}}, expectedIDs: []int{}, expectedTotal: 0},
+		{name: "Several categories", query: db.ProductQuery{Categories: []string{"clothing", "accessories"}}, expectedIDs: []int{3, 4, 5}, expectedTotal: 3},
+		{name: "Minimum price is inclusive", query: db.ProductQuery{MinPrice: price(29.99)}, expectedIDs: []int{1, 2, 5, 6}, expectedTotal: 4},
+		{name: "Maximum price is inclusive", query: db.ProductQuery{MaxPrice: price(19.99)}, expectedIDs: []int{3, 4, 7}, expectedTotal: 3},
+		{name: "Price range", query: db.ProductQuery{MinPrice: price(10), MaxPrice: price(500)}, expectedIDs: []int{1, 3, 4, 5, 6}, expectedTotal: 5},
+		{name: "In stock", query: db.ProductQuery{InStock: true}, expectedIDs: []int{1, 3, 4, 6}, expectedTotal: 4},
+		{name: "Attribute", query: db.ProductQuery{Attributes: map[string][]string{"color": {"black"}}}, expectedIDs: []int{1, 3}, expectedTotal: 2},
+		{name: "Attribute with several values", query: db.ProductQuery{Attributes: map[string][]string{"color": {"black", "red"}}}, expectedIDs: []int{1, 3, 4}, expectedTotal: 3},
+		{name: "Several attributes", query: db.ProductQuery{Attributes: map[string][]string{"color": {"black"}, "size": {"M", "L"}}}, expectedIDs: []int{3}, expectedTotal: 1},
+		{name: "Unknown attribute value", query: db.ProductQuery{Attributes: map[string][]string{"size": {"XL"}}}, expectedIDs: []int{}, expectedTotal: 0},
+		{name: "Every filter", query: db.ProductQuery{Categories: []string{"clothing"}, Search: "shirt", MaxPrice: price(20), InStock: true,
+			Attributes: map[string][]string{"size": {"M"}}}, expectedIDs: []int{3}, expectedTotal: 1},
+		{name: "Search matches word prefixes", query: db.ProductQuery{Search: "PHO"}, expectedIDs: []int{4}, expectedTotal: 1,
+			snippets: []string{"<mark>Phone</mark> Case"}},
+		{name: "Search ignores case beyond ASCII", query: db.ProductQuery{Search: "ähn"}, expectedIDs: []int{7}, expectedTotal: 1},
+		{name: "Search splits on punctuation", query: db.ProductQuery{Search: "T_Sh"}, expectedIDs: []int{3}, expectedTotal: 1},
+		{name: "Search needs every word", query: db.ProductQuery{Search: "shirt cotton"}, expectedIDs: []int{5}, expectedTotal: 1},
+		{name: "Search matches categories", query: db.ProductQuery{Search: "electro"}, expectedIDs: []int{1, 2, 6}, expectedTotal: 3},
+		{name: "Search matches descriptions", query: db.ProductQuery{Search: "notebook"}, expectedIDs: []int{2}, expectedTotal: 1,
+			snippets: []string{"Thin and light <mark>notebook</mark>"}},
+		{name: "Search escapes snippets", query: db.ProductQuery{Search: "wireless"}, expectedIDs: []int{6}, expectedTotal: 1,
+			snippets: []string{"Noise &lt;cancelling&gt; &amp; <mark>wireless</mark>"}},
+		{name: "Search without words matches everything", query: db.ProductQuery{Search: "%"}, expectedIDs: []int{1, 2, 3, 4, 5, 6, 7}, expectedTotal: 7},
+		{name: "Search and category", query: db.ProductQuery{Categories: []string{"clothing"}, Search: "shirt"}, expectedIDs: []int{3, 5}, expectedTotal: 2},
+		{name: "Search keeps ID order by default", query: db.ProductQuery{Search: "case"}, expectedIDs: []int{1, 4}, expectedTotal: 2},
+		{name: "Relevance ranks titles first", query: db.ProductQuery{Search: "case", Sort: sortBy("relevance")}, expectedIDs: []int{4, 1}, expectedTotal: 2,
+			snippets: []string{"Phone <mark>Case</mark>", "Comes with a <mark>case</mark>"}},
+		{name: "Relevance without search", query: db.ProductQuery{Sort: sortBy("relevance")}, expectedIDs: []int{1, 2, 3, 4, 5, 6, 7}, expectedTotal: 7},
+		{name: "Price ascending keeps ties in ID order", query: db.ProductQuery{Sort: sortBy("price")}, expectedIDs: []int{7, 3, 4, 5, 1, 6, 2}, expectedTotal: 7},
+		{name: "Price descending keeps ties in ID order", query: db.ProductQuery{Sort: sortBy("-price")}, expectedIDs: []int{2, 1, 6, 5, 3, 4, 7}, expectedTotal: 7},
+		{name: "Title ignores ASCII case", query: db.ProductQuery{Sort: sortBy("title")}, expectedIDs: []int{5, 6, 2, 4, 1, 3, 7}, expectedTotal: 7},
+		{name: "Newest first", query: db.ProductQuery{Sort: sortBy("newest")}, expectedIDs: []int{7, 6, 5, 4, 3, 2, 1}, expectedTotal: 7},
+		{name: "Oldest first", query: db.ProductQuery{Sort: sortBy("-newest")}, expectedIDs: []int{1, 2, 3, 4, 5, 6, 7}, expectedTotal: 7},
+		{name: "Most popular first", query: db.ProductQuery{Sort: sortBy("-popularity")}, expectedIDs: []int{2, 5, 1, 3, 4, 6, 7}, expectedTotal: 7},
+		{name: "Best rated first", query: db.ProductQuery{Sort: sortBy("-rating")}, expectedIDs: []int{6, 1, 2, 3, 4, 5, 7}, expectedTotal: 7},
+		{name: "Several keys", query: db.ProductQuery{Sort: sortBy("-price,title")}, expectedIDs: []int{2, 6, 1, 5, 4, 3, 7}, expectedTotal: 7},
+		{name: "Relevance then price", query: db.ProductQuery{Search: "electronics", Sort: sortBy("relevance,-price")}, expectedIDs: []int{6, 2, 1}, expectedTotal: 3},
+		{name: "Legacy price sort", query: db.ProductQuery{Sort: sortBy("price_desc")}, expectedIDs: []int{2, 1, 6, 5, 3, 4, 7}, expectedTotal: 7},
+		{name: "Second page", query: db.ProductQuery{Offset: 3, Limit: 3, Sort: sortBy("price")}, expectedIDs: []int{5, 1, 6}, expectedTotal: 7},
+		{name: "Last partial page", query: db.ProductQuery{Offset: 6, Limit: 3}, expectedIDs: []int{7}, expectedTotal: 7},
+		{name: "Page past the end", query: db.ProductQuery{Offset: 12, Limit: 3}, expectedIDs: []int{}, expectedTotal: 7},
+
+		// Cursors don't change the total, which counts every matching product
+		{name: "After cursor", query: db.ProductQuery{After: &db.ProductCursor{ID: 5}}, expectedIDs: []int{6, 7}, expectedTotal: 7},
+		{name: "After cursor with filters", query: db.ProductQuery{Categories: []string{"clothing"}, After: &db.ProductCursor{ID: 3}},
+			expectedIDs: []int{5}, expectedTotal: 2},
+		{name: "After cursor on a price tie", query: db.ProductQuery{Sort: sortBy("price"), Limit: 2,
+			After: &db.ProductCursor{Sort: "price", Price: 19.99, ID: 3}}, expectedIDs: []int{4, 5}, expectedTotal: 7},
+		{name: "After cursor by descending price", query: db.ProductQuery{Sort: sortBy("-price"),
+			After: &db.ProductCursor{Sort: "-price", Price: 499.99, ID: 1}}, expectedIDs: []int{6, 5, 3, 4, 7}, expectedTotal: 7},
+		{name: "After the last product", query: db.ProductQuery{Sort: sortBy("-price"),
+			After: &db.ProductCursor{Sort: "-price", Price: 5, ID: 7}}, expectedIDs: []int{}, expectedTotal: 7},
+		{name: "After cursor on several keys", query: db.ProductQuery{Sort: sortBy("-price,title"),
+			After: &db.ProductCursor{Sort: "-price,title", Price: 499.99, Title: "HEADPHONES", ID: 6}}, expectedIDs: []int{1, 5, 4, 3, 7}, expectedTotal: 7},
+		{name: "After cursor by popularity", query: db.ProductQuery{Sort: sortBy("-popularity"),
+			After: &db.ProductCursor{Sort: "-popularity", Popularity: 1, ID: 5}}, expectedIDs: []int{1, 3, 4, 6, 7}, expectedTotal: 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := store.GetProducts(tc.query)
+			if err != nil {
+				t.Fatalf("Error getting products: %v", err)
+			}
+			total, err := store.CountProducts(tc.query)
+			if err != nil {
+				t.Fatalf("Error counting products: %v", err)
+			}
+			if total != tc.expectedTotal {
+				t.Errorf("Expected total %d, got %d", tc.expectedTotal, total)
+			}
+			if ids := productIDs(results); !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("Expected products %v, got %v", tc.expectedIDs, ids)
+			}
+			if tc.snippets != nil {
+				snippets := []string{}
+				for _, product := range results {
+					snippets = append(snippets, product.Snippet)
+				}
+				if !reflect.DeepEqual(snippets, tc.snippets) {
+					t.Errorf("Expected snippets %q, got %q", tc.snippets, snippets)
+				}
+			}
+		})
+	}
+
+	// Cursors by newest hold the creation time of the product
+	t.Run("After cursor by newest", func(t *testing.T) {
+		product, err := store.GetProductByID(3)
+		if err != nil {
+			t.Fatalf("Error getting product: %v", err)
+		}
+		query := db.ProductQuery{Sort: sortBy("newest")}
+		query.After = query.CursorAfter(*product)
+		if results, _ := store.GetProducts(query); !reflect.DeepEqual(productIDs(results), []int{2, 1}) {
+			t.Errorf("Expected products [2 1], got %v", productIDs(results))
+		}
+	})
+
+	// Facets count the matching products per category, most products first,
+	// and in every price range
+	facetCases := []struct {
+		name               string
+		query              db.ProductQuery
+		expectedCategories []models.CategoryCount
+		expectedPrices     []int
+	}{
+		{name: "Facets of every product", query: db.ProductQuery{Limit: 2, Offset: 2},
+			expectedCategories: []models.CategoryCount{{Category: "clothing", Count: 2}, {Category: "electronics", Count: 2},
+				{Category: "Electronics", Count: 1}, {Category: "accessories", Count: 1}, {Category: "misc", Count: 1}},
+			expectedPrices: []int{1, 2, 1, 0, 0, 2, 1, 0}},
+		{name: "Facets of a search", query: db.ProductQuery{Search: "shirt"},
+			expectedCategories: []models.CategoryCount{{Category: "clothing", Count: 2}}, expectedPrices: []int{0, 1, 1, 0, 0, 0, 0, 0}},
+		{name: "Facets of filtered products", query: db.ProductQuery{InStock: true, After: &db.ProductCursor{ID: 3}},
+			expectedCategories: []models.CategoryCount{{Category: "Electronics", Count: 1}, {Category: "accessories", Count: 1},
+				{Category: "clothing", Count: 1}, {Category: "electronics", Count: 1}},
+			expectedPrices: []int{0, 2, 0, 0, 0, 2, 0, 0}},
+		{name: "Facets without products", query: db.ProductQuery{Categories: []string{"garden"}},
+			expectedCategories: []models.CategoryCount{}, expectedPrices: []int{0, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tc := range facetCases {
+		t.Run(tc.name, func(t *testing.T) {
+			categories, err := store.CountProductsByCategory(tc.query)
+			if err != nil || !reflect.DeepEqual(categories, tc.expectedCategories) {
+				t.Errorf("Expected category counts %+v, got %+v, %v", tc.expectedCategories, categories, err)
+			}
+			ranges, err := store.CountProductsByPrice(tc.query)
+			if err != nil {
+				t.Fatalf("Error counting products by price: %v", err)
+			}
+			counts := []int{}
+			for _, priceRange := range ranges {
+				counts = append(counts, priceRange.Count)
+			}
+			if !reflect.DeepEqual(counts, tc.expectedPrices) {
+				t.Errorf("Expected price range counts %v, got %v", tc.expectedPrices, counts)
+			}
+			if ranges[0].Min != 0 || *ranges[0].Max != 10 || ranges[7].Min != 1000 || ranges[7].Max != nil {
+				t.Errorf("Expected ranges from 0-10 to 1000 and more, got %+v", ranges)
+			}
+		})
+	}
+}
+
+// testSearchRanking pins the order of relevance every backend must agree on,
+// even though SQLite and the memory store rank with bm25 and Postgres with
+// ts_rank: matches in titles rank above matches in categories, which rank
+// above matches in descriptions, and matches in more fields rank higher
+func testSearchRanking(t *testing.T, store db.Store) {
+	products := []models.Product{
+		{Title: "Bookshelf", Category: "furniture", Description: "Solid walnut frame with five shelves"},
+		{Title: "Armchair", Category: "walnut", Description: "Upholstered in linen"},
+		{Title: "Walnut desk", Category: "furniture", Description: "Two drawers and a cable tray"},
+		{Title: "Floor lamp", Category: "lighting", Description: "Brass finish"},
+		{Title: "Walnut stool", Category: "furniture", Description: "Oiled walnut seat"},
+		{Title: "Rug", Category: "textiles", Description: "Hand woven wool"},
+	}
+	for _, product := range products {
+		product.Price = 10
+		product.Image = "https://example.com/image.jpg"
+		if _, err := store.CreateProduct(product); err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+	}
+
+	for _, search := range []string{"walnut", "WALN"} {
+		results, err := store.GetProducts(db.ProductQuery{Search: search, Sort: sortBy("relevance")})
+		if err != nil {
+			t.Fatalf("Error getting products: %v", err)
+		}
+		if ids := productIDs(results); !reflect.DeepEqual(ids, []int{5, 3, 2, 1}) {
+			t.Errorf("Expected products [5 3 2 1] by relevance to %q, got %v", search, ids)
+		}
+	}
+}
+
+func testCategories(t *testing.T, store db.Store) {
+	// Products create their categories, as top-level ones
+	laptop, err := store.CreateProduct(models.Product{Title: "Laptop", Price: 999, Category: "electronics", Image: "https://example.com/laptop.jpg"})
+	if err != nil {
+		t.Fatalf("Error creating product: %v", err)
+	}
+	if category, err := store.GetCategory("electronics"); err != nil || category.Name != "electronics" || category.Parent != "" || category.ProductCount != 1 {
+		t.Errorf("Expected the electronics category with 1 product, got %+v, %v", category, err)
+	}
+
+	phones, err := store.CreateCategory(models.Category{Slug: "phones", Name: "Phones", Parent: "electronics"})
+	if err != nil || phones.ID != 2 || phones.Parent != "electronics" {
+		t.Fatalf("Expected phones with ID 2 under electronics, got %+v, %v", phones, err)
+	}
+	if _, err := store.CreateCategory(models.Category{Slug: "smartphones", Name: "Smartphones", Parent: "phones"}); err != nil {
+		t.Fatalf("Error creating category: %v", err)
+	}
+	if _, err := store.CreateCategory(models.Category{Slug: "phones", Name: "Other phones"}); !errors.Is(err, db.ErrCategoryExists) {
+		t.Errorf("Expected ErrCategoryExists, got %v", err)
+	}
+	if _, err := store.CreateCategory(models.Category{Slug: "tablets", Name: "Tablets", Parent: "computers"}); !errors.Is(err, db.ErrParentNotFound) {
+		t.Errorf("Expected ErrParentNotFound, got %v", err)
+	}
+
+	for _, product := range []models.Product{
+		{Title: "Smartphone", Price: 499, Category: "smartphones"},
+		{Title: "T-Shirt", Price: 19, Category: "clothing"},
+	} {
+		product.Image = "https://example.com/image.jpg"
+		if _, err := store.CreateProduct(product); err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+	}
+
+	expected := []models.Category{
+		{ID: 1, Slug: "electronics", Name: "electronics", ProductCount: 1},
+		{ID: 2, Slug: "phones", Name: "Phones", Parent: "electronics"},
+		{ID: 3, Slug: "smartphones", Name: "Smartphones", Parent: "phones", ProductCount: 1},
+		{ID: 4, Slug: "clothing", Name: "clothing", ProductCount: 1},
+	}
+	if categories, err := store.GetCategories(); err != nil || !reflect.DeepEqual(categories, expected) {
+		t.Errorf("Expected categories %+v, got %+v, %v", expected, categories, err)
+	}
+
+	// Filters include subcategories on request only
+	filters := []struct {
+		query       db.ProductQuery
+		expectedIDs []int
+	}{
+		{query: db.ProductQuery{Categories: []string{"electronics"}}, expectedIDs: []int{1}},
+		{query: db.ProductQuery{Categories: []string{"electronics"}, Subcategories: true}, expectedIDs: []int{1, 2}},
+		{query: db.ProductQuery{Categories: []string{"phones"}, Subcategories: true}, expectedIDs: []int{2}},
+		{query: db.ProductQuery{Categories: []string{"phones", "clothing"}, Subcategories: true}, expectedIDs: []int{2, 3}},
+		{query: db.ProductQuery{Categories: []string{"garden"}, Subcategories: true}, expectedIDs: []int{}},
+	}
+	for _, filter := range filters {
+		results, total, err := listProducts(store, filter.query)
+		if err != nil || total != len(filter.expectedIDs) || !reflect.DeepEqual(productIDs(results), filter.expectedIDs) {
+			t.Errorf("Expected products %v for %+v, got %v of %d, %v", filter.expectedIDs, filter.query, productIDs(results), total, err)
+		}
+	}
+
+	// Categories are renamed and moved, but never under themselves
+	if err := store.UpdateCategory(models.Category{Slug: "electronics", Name: "Electronics"}); err != nil {
+		t.Errorf("Error renaming category: %v", err)
+	}
+	if err := store.UpdateCategory(models.Category{Slug: "smartphones", Name: "Smartphones"}); err != nil {
+		t.Errorf("Error moving category to the top level: %v", err)
+	}
+	if category, _ := store.GetCategory("smartphones"); category.Parent != "" {
+		t.Errorf("Expected smartphones at the top level, got %+v", category)
+	}
+	if err := store.UpdateCategory(models.Category{Slug: "electronics", Name: "Electronics", Parent: "smartphones"}); err != nil {
+		t.Errorf("Error moving category: %v", err)
+	}
+	for _, parent := range []string{"electronics", "phones"} {
+		err := store.UpdateCategory(models.Category{Slug: "electronics", Name: "Electronics", Parent: parent})
+		if !errors.Is(err, db.ErrCategoryCycle) {
+			t.Errorf("Expected ErrCategoryCycle moving electronics under %s, got %v", parent, err)
+		}
+	}
+	if err := store.UpdateCategory(models.Category{Slug: "garden", Name: "Garden"}); !errors.Is(err, db.ErrCategoryNotFound) {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+	if err := store.UpdateCategory(models.Category{Slug: "phones", Name: "Phones", Parent: "garden"}); !errors.Is(err, db.ErrParentNotFound) {
+		t.Errorf("Expected ErrParentNotFound, got %v", err)
+	}
+
+	// Updated products create their new categories as well
+	laptop.Category = "computers"
+	if err := store.UpdateProduct(*laptop); err != nil {
+		t.Fatalf("Error updating product: %v", err)
+	}
+	if category, err := store.GetCategory("computers"); err != nil || category.ID != 5 || category.ProductCount != 1 {
+		t.Errorf("Expected the computers category with 1 product, got %+v, %v", category, err)
+	}
+
+	// Only categories without products and subcategories are deleted
+	for _, slug := range []string{"smartphones", "computers"} {
+		if err := store.DeleteCategory(slug); !errors.Is(err, db.ErrCategoryInUse) {
+			t.Errorf("Expected ErrCategoryInUse deleting %s, got %v", slug, err)
+		}
+	}
+	if err := store.DeleteCategory("phones"); err != nil {
+		t.Errorf("Error deleting category: %v", err)
+	}
+	if _, err := store.GetCategory("phones"); !errors.Is(err, db.ErrCategoryNotFound) {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+	if err := store.DeleteCategory("phones"); !errors.Is(err, db.ErrCategoryNotFound) {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+}
+
+func testFavorites(t *testing.T, store db.Store) {
+	createUsers(t, store, 3)
+	var productIDs []int
+	for i := 1; i <= 3; i++ {
+		product, err := store.CreateProduct(models.Product{Title: fmt.Sprintf("Product %d", i), Price: float64(i), Category: "test", Image: "https://example.com/image.jpg"})
+		if err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+		productIDs = append(productIDs, product.ID)
+	}
+
+	for _, id := range productIDs {
+		if err := store.AddFavorite(1, id, fmt.Sprintf("note %d", id)); err != nil {
+			t.Fatalf("Error adding favorite: %v", err)
+		}
+	}
+	if err := store.AddFavorite(2, productIDs[0], ""); err != nil {
+		t.Fatalf("Error adding favorite: %v", err)
+	}
+	if err := store.AddFavorite(1, 999, ""); !errors.Is(err, db.ErrProductNotFound) {
+		t.Errorf("Expected ErrProductNotFound, got %v", err)
+	}
+	if err := store.AddFavorite(999, productIDs[0], ""); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	// Most recently added first, paginated, with totals per user
+	favorites, total, err := store.GetFavorites(1, 1, 2)
+	if err != nil {
+		t.Fatalf("Error getting favorites: %v", err)
+	}
+	if total != 3 || !reflect.DeepEqual(favoriteIDs(favorites), []int{3, 2}) {
+		t.Errorf("Expected products [3 2] of 3, got %v of %d", favoriteIDs(favorites), total)
+	}
+	if favorites[0].Notes != "note 3" || favorites[0].Title != "Product 3" || favorites[0].CreatedAt.IsZero() {
+		t.Errorf("Unexpected favorite: %+v", favorites[0])
+	}
+	if favorites, _, _ := store.GetFavorites(1, 2, 2); !reflect.DeepEqual(favoriteIDs(favorites), []int{1}) {
+		t.Errorf("Expected products [1] on page 2, got %v", favoriteIDs(favorites))
+	}
+	if favorites, total, _ := store.GetFavorites(3, 1, 10); total != 0 || len(favorites) != 0 {
+		t.Errorf("Expected no favorites, got %d", total)
+	}
+
+	// Adding a favorite again updates its notes without duplicating or reordering it
+	original, _, _ := store.GetFavorites(1, 1, 10)
+	if err := store.AddFavorite(1, productIDs[0], "updated"); err != nil {
+		t.Fatalf("Error updating favorite: %v", err)
+	}
+	favorites, total, _ = store.GetFavorites(1, 1, 10)
+	if total != 3 || !reflect.DeepEqual(favoriteIDs(favorites), []int{3, 2, 1}) {
+		t.Fatalf("Expected products [3 2 1] of 3, got %v of %d", favoriteIDs(favorites), total)
+	}
+	if favorites[2].Notes != "updated" || !favorites[2].CreatedAt.Equal(original[2].CreatedAt) {
+		t.Errorf("Expected updated notes and unchanged created_at, got %+v", favorites[2])
+	}
+	if favorites[2].UpdatedAt.Before(favorites[2].CreatedAt) {
+		t.Errorf("Expected updated_at not before created_at, got %+v", favorites[2])
+	}
+
+	if err := store.RemoveFavorite(1, productIDs[1]); err != nil {
+		t.Fatalf("Error removing favorite: %v", err)
+	}
+	if err := store.RemoveFavorite(1, productIDs[1]); !errors.Is(err, db.ErrFavoriteNotFound) {
+		t.Errorf("Expected ErrFavoriteNotFound, got %v", err)
+	}
+	if favorites, total, _ := store.GetFavorites(1, 1, 10); total != 2 || !reflect.DeepEqual(favoriteIDs(favorites), []int{3, 1}) {
+		t.Errorf("Expected products [3 1] of 2, got %v of %d", favoriteIDs(favorites), total)
+	}
+}
+
+func testDeleteProductCascade(t *testing.T, store db.Store) {
+	createUsers(t, store, 3)
+	product, err := store.CreateProduct(models.Product{Title: "Lamp", Price: 25, Category: "home", Image: "https://example.com/lamp.jpg"})
+	if err != nil {
+		t.Fatalf("Error creating product: %v", err)
+	}
+	if err := store.AddFavorite(1, product.ID, ""); err != nil {
+		t.Fatalf("Error adding favorite: %v", err)
+	}
+
+	if err := store.DeleteProduct(product.ID); err != nil {
+		t.Fatalf("Error deleting product: %v", err)
+	}
+	if _, total, _ := store.GetFavorites(1, 1, 10); total != 0 {
+		t.Errorf("Expected the favorite to be deleted with its product, got %d favorites", total)
+	}
+	if err := store.RemoveFavorite(1, product.ID); !errors.Is(err, db.ErrFavoriteNotFound) {
+		t.Errorf("Expected ErrFavoriteNotFound, got %v", err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, store db.Store) {
+	createUsers(t, store, 3)
+	expiresAt := time.Now().Add(time.Hour)
+
+	if err := store.CreateRefreshToken(1, "family", "first", expiresAt); err != nil {
+		t.Fatalf("Error creating refresh token: %v", err)
+	}
+	if err := store.CreateRefreshToken(1, "family", "first", expiresAt); err == nil {
+		t.Errorf("Expected an error for a duplicate token hash")
+	}
+	if err := store.CreateRefreshToken(999, "family", "orphan", expiresAt); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	// Rotation replaces the token
+	userID, err := store.RotateRefreshToken("first", "second", expiresAt)
+	if err != nil || userID != 1 {
+		t.Fatalf("Expected rotation for user 1, got %d, %v", userID, err)
+	}
+	if _, err := store.RotateRefreshToken("unknown", "other", expiresAt); !errors.Is(err, db.ErrRefreshTokenNotFound) {
+		t.Errorf("Expected ErrRefreshTokenNotFound, got %v", err)
+	}
+
+	// Reusing a rotated token revokes its whole family
+	userID, err = store.RotateRefreshToken("first", "third", expiresAt)
+	if !errors.Is(err, db.ErrRefreshTokenReused) || userID != 1 {
+		t.Errorf("Expected ErrRefreshTokenReused for user 1, got %d, %v", userID, err)
+	}
+	if _, err := store.RotateRefreshToken("second", "third", expiresAt); !errors.Is(err, db.ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// Expired tokens are rejected and purged
+	if err := store.CreateRefreshToken(1, "old", "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Error creating refresh token: %v", err)
+	}
+	if _, err := store.RotateRefreshToken("expired", "other", expiresAt); !errors.Is(err, db.ErrRefreshTokenExpired) {
+		t.Errorf("Expected ErrRefreshTokenExpired, got %v", err)
+	}
+	if deleted, err := store.DeleteExpiredRefreshTokens(); err != nil || deleted != 1 {
+		t.Errorf("Expected 1 expired token deleted, got %d, %v", deleted, err)
+	}
+	if _, err := store.RotateRefreshToken("expired", "other", expiresAt); !errors.Is(err, db.ErrRefreshTokenNotFound) {
+		t.Errorf("Expected ErrRefreshTokenNotFound, got %v", err)
+	}
+
+	// Families are only revoked for their own user
+	store.CreateRefreshToken(2, "session-a", "a", expiresAt)
+	store.CreateRefreshToken(2, "session-b", "b", expiresAt)
+	if err := store.RevokeRefreshTokenFamily(1, "a"); err != nil {
+		t.Fatalf("Error revoking family: %v", err)
+	}
+	if _, err := store.RotateRefreshToken("a", "a2", expiresAt); err != nil {
+		t.Errorf("Expected a token of another user to stay valid, got %v", err)
+	}
+	if err := store.RevokeRefreshTokenFamily(2, "a2"); err != nil {
+		t.Fatalf("Error revoking family: %v", err)
+	}
+	if _, err := store.RotateRefreshToken("a2", "a3", expiresAt); !errors.Is(err, db.ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := store.RotateRefreshToken("b", "b2", expiresAt); err != nil {
+		t.Errorf("Expected other sessions to stay valid, got %v", err)
+	}
+
+	// Revoking a user's tokens revokes every session
+	if err := store.RevokeUserRefreshTokens(2); err != nil {
+		t.Fatalf("Error revoking tokens: %v", err)
+	}
+	if _, err := store.RotateRefreshToken("b2", "b3", expiresAt); !errors.Is(err, db.ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+}
+
+func testRevokedTokens(t *testing.T, store db.Store) {
+	createUsers(t, store, 3)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	if err := store.RevokeToken("active", 1, expiresAt); err != nil {
+		t.Fatalf("Error revoking token: %v", err)
+	}
+	// Revoking again keeps the first expiry
+	if err := store.RevokeToken("active", 1, expiresAt.Add(time.Hour)); err != nil {
+		t.Fatalf("Error revoking token again: %v", err)
+	}
+	if err := store.RevokeToken("expired", 1, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Error revoking token: %v", err)
+	}
+	if err := store.RevokeToken("orphan", 999, expiresAt); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	revoked, err := store.GetRevokedTokens()
+	if err != nil {
+		t.Fatalf("Error getting revoked tokens: %v", err)
+	}
+	if len(revoked) != 1 || !revoked["active"].Equal(expiresAt) {
+		t.Errorf("Expected only the active token expiring at %v, got %v", expiresAt, revoked)
+	}
+
+	if deleted, err := store.DeleteExpiredRevokedTokens(); err != nil || deleted != 1 {
+		t.Errorf("Expected 1 expired token deleted, got %d, %v", deleted, err)
+	}
+	if deleted, _ := store.DeleteExpiredRevokedTokens(); deleted != 0 {
+		t.Errorf("Expected nothing left to delete, got %d", deleted)
+	}
+}
+
+// TestConcurrentWrites checks that every backend handles concurrent writers,
+// including transactions that read before writing, without failing
+// TestNewestSortsOnCreationTime checks that newest follows the creation time
+// of products rather than their IDs, which only break ties
+func TestNewestSortsOnCreationTime(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := db.OpenSQLite(dbPath, testOptions)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer store.Close()
+	for _, title := range []string{"Lamp", "Desk", "Chair"} {
+		if _, err := store.CreateProduct(models.Product{Title: title, Price: 10, Category: "home"}); err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+	}
+
+	// Products can't be backdated through the store
+	conn, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("UPDATE products SET created_at = '2030-01-01 00:00:00' WHERE id = 1"); err != nil {
+		t.Fatalf("Error updating creation time: %v", err)
+	}
+
+	product, err := store.GetProductByID(1)
+	if err != nil || !product.CreatedAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected product 1 created in 2030, got %+v, %v", product, err)
+	}
+
+	testCases := []struct {
+		name        string
+		sort        string
+		after       int
+		expectedIDs []int
+	}{
+		{name: "Newest first", sort: "newest", expectedIDs: []int{1, 3, 2}},
+		{name: "Oldest first", sort: "-newest", expectedIDs: []int{2, 3, 1}},
+		{name: "After cursor", sort: "newest", after: 1, expectedIDs: []int{3, 2}},
+		{name: "After cursor on a tie", sort: "newest", after: 3, expectedIDs: []int{2}},
+		{name: "After cursor on a tie, oldest first", sort: "-newest", after: 2, expectedIDs: []int{3, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := db.ProductQuery{Sort: sortBy(tc.sort)}
+			if tc.after != 0 {
+				product, err := store.GetProductByID(tc.after)
+				if err != nil {
+					t.Fatalf("Error getting product: %v", err)
+				}
+				query.After = query.CursorAfter(*product)
+			}
+			results, err := store.GetProducts(query)
+			if err != nil {
+				t.Fatalf("Error getting products: %v", err)
+			}
+			if ids := productIDs(results); !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("Expected products %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	skipUnavailable(t)
+	for backend, open := range backends {
+		t.Run(backend, func(t *testing.T) {
+			t.Parallel()
+			store := open(t)
+
+			product, err := store.CreateProduct(models.Product{Title: "Lamp", Price: 25, Category: "home", Image: "https://example.com/lamp.jpg"})
+			if err != nil {
+				t.Fatalf("Error creating product: %v", err)
+			}
+			const users = 20
+			createUsers(t, store, users)
+
+			// Every user favorites the product, some of them twice, and
+			// rotates a refresh token
+			var wg sync.WaitGroup
+			errs := make(chan error, users*4)
+			for userID := 1; userID <= users; userID++ {
+				wg.Add(1)
+				go func(userID int) {
+					defer wg.Done()
+					for i := 0; i <= userID%2; i++ {
+						if err := store.AddFavorite(userID, product.ID, fmt.Sprintf("note %d", i)); err != nil {
+							errs <- fmt.Errorf("error adding favorite: %w", err)
+						}
+					}
+					expiresAt := time.Now().Add(time.Hour)
+					first, second := fmt.Sprintf("first-%d", userID), fmt.Sprintf("second-%d", userID)
+					if err := store.CreateRefreshToken(userID, first, first, expiresAt); err != nil {
+						errs <- fmt.Errorf("error creating refresh token: %w", err)
+						return
+					}
+					if _, err := store.RotateRefreshToken(first, second, expiresAt); err != nil {
+						errs <- fmt.Errorf("error rotating refresh token: %w", err)
+					}
+				}(userID)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			for userID := 1; userID <= users; userID++ {
+				if _, total, err := store.GetFavorites(userID, 1, 10); err != nil || total != 1 {
+					t.Errorf("Expected exactly 1 favorite for user %d, got %d, %v", userID, total, err)
+				}
+			}
+		})
+	}
+}
+
+// TestBackendsAgree runs the same random product queries against every
+// backend and expects identical results
+func TestBackendsAgree(t *testing.T) {
+	skipUnavailable(t)
+	rng := rand.New(rand.NewSource(1))
+	categories := []string{"a", "b", "c"}
+	words := []string{"red", "Blue", "green", "LAMP", "chair", "desk", "x_y", "50%"}
+	colors := []string{"red", "blue", "green"}
+
+	var products []models.Product
+	for i := 0; i < 60; i++ {
+		// Descriptions of several sentences, long enough to be cut in snippets
+		var description strings.Builder
+		for j := rng.Intn(30); j > 0; j-- {
+			description.WriteString(words[rng.Intn(len(words))] + []string{" ", ". ", ": "}[rng.Intn(3)])
+		}
+		attributes := map[string]string{}
+		if rng.Intn(4) > 0 {
+			attributes["color"] = colors[rng.Intn(len(colors))]
+		}
+		products = append(products, models.Product{
+			Title:       words[rng.Intn(len(words))] + " " + words[rng.Intn(len(words))],
+			Description: description.String(),
+			Price:       float64(rng.Intn(20)) + 0.99,
+			Category:    categories[rng.Intn(len(categories))],
+			Image:       "https://example.com/image.jpg",
+			Stock:       rng.Intn(3),
+			Rating:      float64(rng.Intn(11)) / 2,
+			Attributes:  attributes,
+		})
+	}
+
+	// Users favorite products at random; products were created with IDs from 1
+	const users = 5
+	favorites := [][2]int{}
+	for userID := 1; userID <= users; userID++ {
+		for n := range products {
+			if rng.Intn(4) == 0 {
+				favorites = append(favorites, [2]int{userID, n + 1})
+				products[n].Popularity++
+			}
+		}
+	}
+
+	stores := map[string]db.Store{}
+	for backend, open := range backends {
+		stores[backend] = open(t)
+		for _, product := range products {
+			if _, err := stores[backend].CreateProduct(product); err != nil {
+				t.Fatalf("%s: error creating product: %v", backend, err)
+			}
+		}
+		// b is a subcategory of a, which has another empty one
+		if err := stores[backend].UpdateCategory(models.Category{Slug: "b", Name: "B", Parent: "a"}); err != nil {
+			t.Fatalf("%s: error moving category: %v", backend, err)
+		}
+		if _, err := stores[backend].CreateCategory(models.Category{Slug: "d", Name: "D", Parent: "a"}); err != nil {
+			t.Fatalf("%s: error creating category: %v", backend, err)
+		}
+		createUsers(t, stores[backend], users)
+		for _, favorite := range favorites {
+			if err := stores[backend].AddFavorite(favorite[0], favorite[1], ""); err != nil {
+				t.Fatalf("%s: error adding favorite: %v", backend, err)
+			}
+		}
+	}
+
+	expectedCategories, err := stores["sqlite"].GetCategories()
+	if err != nil {
+		t.Fatalf("sqlite: error getting categories: %v", err)
+	}
+	for backend, store := range stores {
+		if categories, err := store.GetCategories(); err != nil || !reflect.DeepEqual(categories, expectedCategories) {
+			t.Errorf("%s disagrees with sqlite on categories: %+v vs %+v, %v", backend, categories, expectedCategories, err)
+		}
+	}
+
+	searches := []string{"", "e", "LAMP", "lamp", "blue ch", "ch blue", "_", "%", "x_", "y", "50%", "de"}
+	sorts := []string{"", "price", "-price", "relevance", "title", "-title,price", "newest", "-popularity", "popularity,-rating",
+		"-rating,title", "relevance,-price"}
+	for i := 0; i < 300; i++ {
+		query := db.ProductQuery{
+			Offset:  rng.Intn(30) - 5,
+			Limit:   rng.Intn(15),
+			Sort:    sortBy(sorts[rng.Intn(len(sorts))]),
+			Search:  searches[rng.Intn(len(searches))],
+			InStock: rng.Intn(4) == 0,
+
+			Subcategories: rng.Intn(2) == 0,
+		}
+		for _, category := range append(categories, "d") {
+			if rng.Intn(3) == 0 {
+				query.Categories = append(query.Categories, category)
+			}
+		}
+		if rng.Intn(3) == 0 {
+			query.MinPrice = price(float64(rng.Intn(20)) + 0.99)
+		}
+		if rng.Intn(3) == 0 {
+			query.MaxPrice = price(float64(rng.Intn(20)))
+		}
+		if rng.Intn(3) == 0 {
+			query.Attributes = map[string][]string{"color": colors[:1+rng.Intn(2)]}
+		}
+
+		if query.SupportsCursor() && rng.Intn(3) == 0 {
+			n := rng.Intn(len(products))
+			product := products[n]
+			product.ID = n + 1
+			query.After = query.CursorAfter(product)
+		}
+
+		expected, expectedTotal, err := listProducts(stores["sqlite"], query)
+		if err != nil {
+			t.Fatalf("sqlite: %v", err)
+		}
+		for backend, store := range stores {
+			results, total, err := listProducts(store, query)
+			if err != nil {
+				t.Fatalf("%s: %v", backend, err)
+			}
+			// Postgres ranks matches with ts_rank rather than BM25
+			if backend == "postgres" && !query.SupportsCursor() && total == expectedTotal {
+				continue
+			}
+			if total != expectedTotal || !reflect.DeepEqual(results, expected) {
+				t.Errorf("%s disagrees with sqlite on %+v: %v of %d vs %v of %d",
+					backend, query, productIDs(results), total, productIDs(expected), expectedTotal)
+			}
+		}
+
+		// Facets don't depend on the order of the products
+		expectedCategories, err := stores["sqlite"].CountProductsByCategory(query)
+		if err != nil {
+			t.Fatalf("sqlite: %v", err)
+		}
+		expectedPrices, err := stores["sqlite"].CountProductsByPrice(query)
+		if err != nil {
+			t.Fatalf("sqlite: %v", err)
+		}
+		for backend, store := range stores {
+			categories, err := store.CountProductsByCategory(query)
+			if err != nil || !reflect.DeepEqual(categories, expectedCategories) {
+				t.Errorf("%s disagrees with sqlite on the categories of %+v: %+v vs %+v, %v", backend, query, categories, expectedCategories, err)
+			}
+			prices, err := store.CountProductsByPrice(query)
+			if err != nil || !reflect.DeepEqual(prices, expectedPrices) {
+				t.Errorf("%s disagrees with sqlite on the prices of %+v: %+v vs %+v, %v", backend, query, prices, expectedPrices, err)
+			}
+		}
+	}
+}
+
+// createUsers creates users with IDs 1 to n in an empty store
+func createUsers(t *testing.T, store db.Store, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		user, err := store.CreateUser(fmt.Sprintf("user%d", i), "secret123", models.RoleUser)
+		if err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+		if user.ID != i {
+			t.Fatalf("Expected user ID %d, got %d", i, user.ID)
+		}
+	}
+}
+
+// listProducts gets the products of a query along with their count. Creation
+// times are left out, since stores filled one after the other may not agree on
+// them.
+func listProducts(store db.Store, query db.ProductQuery) ([]models.Product, int, error) {
+	products, err := store.GetProducts(query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting products: %w", err)
+	}
+	for i := range products {
+		products[i].CreatedAt = time.Time{}
+	}
+	total, err := store.CountProducts(query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting products: %w", err)
+	}
+	return products, total, nil
+}
+
+// sortBy parses a sort of a test case
+func sortBy(value string) db.Sort {
+	sort, err := db.ParseSort(value)
+	if err != nil {
+		panic(err)
+	}
+	return sort
+}
+
+// price returns a pointer to a price bound
+func price(p float64) *float64 {
+	return &p
+}
+
+// productIDs returns the IDs of the products in order
+func productIDs(products []models.Product) []int {
+	ids := []int{}
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
+
+// favoriteIDs returns the product IDs of the favorites in order
+func favoriteIDs(favorites []models.FavoriteProduct) []int {
+	ids := []int{}
+	for _, favorite := range favorites {
+		ids = append(ids, favorite.ID)
+	}
+	return ids
+}
@@ -1,24 +1,30 @@
 package db
 
 import (
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/password"
 )
 
+// ErrUserNotFound is returned when no user matches the lookup
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUsernameTaken is returned when creating a user whose username already exists
+var ErrUsernameTaken = errors.New("username already taken")
+
 // GetUserByUsername retrieves a user by username
-func GetUserByUsername(username string) (*models.User, error) {
+func (s *SQLiteStore) GetUserByUsername(username string) (*models.User, error) {
 	var user models.User
-	err := DB.QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).Scan(
-		&user.ID, &user.Username, &user.Password,
+	err := s.db.QueryRow("SELECT id, username, password, role, token_version FROM users WHERE username = ?", username).Scan(
+		&user.ID, &user.Username, &user.Password, &user.Role, &user.TokenVersion,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("error querying user: %w", err)
 	}
@@ -26,27 +32,37 @@ func GetUserByUsername(username string) (*models.User, error) {
 }
 
 // GetUserByID retrieves a user by ID
-func GetUserByID(id int) (*models.User, error) {
+func (s *SQLiteStore) GetUserByID(id int) (*models.User, error) {
 	var user models.User
-	err := DB.QueryRow("SELECT id, username, password FROM users WHERE id = ?", id).Scan(
-		&user.ID, &user.Username, &user.Password,
+	err := s.db.QueryRow("SELECT id, username, password, role, token_version FROM users WHERE id = ?", id).Scan(
+		&user.ID, &user.Username, &user.Password, &user.Role, &user.TokenVersion,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("error querying user: %w", err)
 	}
 	return &user, nil
 }
 
-// CreateUser creates a new user
-func CreateUser(username, password string) (*models.User, error) {
+// CreateUser creates a new user with the given role
+func (s *SQLiteStore) CreateUser(username, plaintext, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
 	// Hash the password
-	hashedPassword := hashPassword(password)
+	hashedPassword, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
 
-	result, err := DB.Exec("INSERT INTO users (username, password) VALUES (?, ?)", username, hashedPassword)
+	result, err := s.db.Exec("INSERT INTO users (username, password, role) VALUES (?, ?, ?)", username, hashedPassword, role)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrUsernameTaken
+		}
 		return nil, fmt.Errorf("error creating user: %w", err)
 	}
 
@@ -59,16 +75,115 @@ func CreateUser(username, password string) (*models.User, error) {
 		ID:       int(id),
 		Username: username,
 		Password: hashedPassword,
+		Role:     role,
 	}, nil
 }
 
-// hashPassword hashes a password using SHA-256
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
+// SetUserRole changes the role of an existing user
+func (s *SQLiteStore) SetUserRole(id int, role string) error {
+	if !models.ValidRole(role) {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	result, err := s.db.Exec("UPDATE users SET role = ? WHERE id = ?", role, id)
+	if err != nil {
+		return fmt.Errorf("error updating user role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetTokenVersion retrieves a user's current token version. Access tokens
+// carrying an older version are no longer accepted.
+func (s *SQLiteStore) GetTokenVersion(id int) (int, error) {
+	var version int
+	err := s.db.QueryRow("SELECT token_version FROM users WHERE id = ?", id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("error querying token version: %w", err)
+	}
+	return version, nil
+}
+
+// IncrementTokenVersion invalidates every access token issued to a user so far
+// and returns the new token version
+func (s *SQLiteStore) IncrementTokenVersion(id int) (int, error) {
+	var version int
+	err := s.db.QueryRow("UPDATE users SET token_version = token_version + 1 WHERE id = ? RETURNING token_version", id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("error updating token version: %w", err)
+	}
+	return version, nil
+}
+
+// UpdatePassword replaces a user's password hash with a fresh hash of the
+// password produced by the store's hasher
+func (s *SQLiteStore) UpdatePassword(id int, plaintext string) error {
+	hashedPassword, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	result, err := s.db.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, id)
+	if err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// validatePassword validates a password against a stored hash. needsRehash
+// reports whether a matching hash was produced by another algorithm or
+// parameters than hasher's and should be replaced using UpdatePassword.
+func validatePassword(hasher password.Hasher, plaintext, hash string) (ok, needsRehash bool) {
+	ok, needsRehash, err := password.Verify(hasher, plaintext, hash)
+	if err != nil {
+		return false, false
+	}
+	return ok, needsRehash
+}
+
+// dummyPasswordHash returns a function hashing a fixed password with hasher
+// on its first call and returning that hash from then on
+func dummyPasswordHash(hasher password.Hasher) func() string {
+	return sync.OnceValue(func() string {
+		hash, err := hasher.Hash("dummy password")
+		if err != nil {
+			return ""
+		}
+		return hash
+	})
+}
+
+// ValidatePassword validates a password against a stored hash
+func (s *SQLiteStore) ValidatePassword(plaintext, hash string) (ok, needsRehash bool) {
+	return validatePassword(s.hasher, plaintext, hash)
 }
 
-// ValidatePassword validates a password against a hash
-func ValidatePassword(password, hash string) bool {
-	return hashPassword(password) == hash
+// ValidateDummyPassword validates a password against a hash no user has
+func (s *SQLiteStore) ValidateDummyPassword(plaintext string) {
+	validatePassword(s.hasher, plaintext, s.dummyHash())
 }
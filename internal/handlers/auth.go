@@ -2,21 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/najwa/product-catalog-api/internal/auth"
 	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/middleware"
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
-// LoginHandler handles user login and returns a JWT token
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
+// LoginHandler handles user login and returns an access token and a refresh token
+func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -31,27 +34,289 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the user from the database
-	user, err := db.GetUserByUsername(req.Username)
+	user, err := h.Users.GetUserByUsername(req.Username)
 	if err != nil {
+		// Unknown usernames take as long to reject as wrong passwords
+		if errors.Is(err, db.ErrUserNotFound) {
+			h.Users.ValidateDummyPassword(req.Password)
+		}
 		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	// Validate the password
-	if !db.ValidatePassword(req.Password, user.Password) {
+	ok, needsRehash := h.Users.ValidatePassword(req.Password, user.Password)
+	if !ok {
 		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
+	// Upgrade hashes produced by an outdated algorithm now that the plaintext is known.
+	// A failure here must not prevent the user from logging in.
+	if needsRehash {
+		if err := h.Users.UpdatePassword(user.ID, req.Password); err != nil {
+			log.Printf("Error upgrading password hash for user %d: %v", user.ID, err)
+		}
+	}
+
+	// Generate the access and refresh tokens
+	response, err := h.issueTokens(user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// Return the tokens
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// new refresh token. The presented refresh token can not be used again.
+func (h *Handlers) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	// Generate the replacement refresh token
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// Rotate the refresh token
+	userID, err := h.Tokens.RotateRefreshToken(
+		auth.HashRefreshToken(req.RefreshToken),
+		auth.HashRefreshToken(refreshToken),
+		time.Now().Add(h.Auth.RefreshTokenExpiry()),
+	)
+	switch {
+	case errors.Is(err, db.ErrRefreshTokenReused):
+		log.Printf("Refresh token reuse detected for user %d, session revoked", userID)
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	case errors.Is(err, db.ErrRefreshTokenNotFound), errors.Is(err, db.ErrRefreshTokenExpired):
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Error refreshing token")
+		return
+	}
+
+	// Get the user from the database for their current role
+	user, err := h.Users.GetUserByID(userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
 	// Generate a JWT token
-	token, err := auth.GenerateToken(user.ID)
+	token, err := h.Auth.GenerateToken(user.ID, user.Role, user.TokenVersion)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// Return the tokens
+	respondWithJSON(w, http.StatusOK, models.LoginResponse{
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.Auth.TokenExpiry().Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+// LogoutHandler revokes the caller's access token and, if provided, the
+// refresh token of the same session. With all_sessions set, every access and
+// refresh token of the user is revoked instead.
+func (h *Handlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the token claims from the context
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the optional request body
+	var req models.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.AllSessions {
+		// Invalidate every access token and refresh token of the user
+		if err := h.Revocations.RevokeAllForUser(claims.UserID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error logging out")
+			return
+		}
+		if err := h.Tokens.RevokeUserRefreshTokens(claims.UserID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error logging out")
+			return
+		}
+	} else {
+		// Invalidate this session only
+		if err := h.Revocations.Revoke(claims); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error logging out")
+			return
+		}
+		if req.RefreshToken != "" {
+			if err := h.Tokens.RevokeRefreshTokenFamily(claims.UserID, auth.HashRefreshToken(req.RefreshToken)); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error logging out")
+				return
+			}
+		}
+	}
+
+	// Return success
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
+}
+
+// JWKSHandler publishes the public keys used to sign tokens as a JSON Web Key Set
+func (h *Handlers) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	// Let verifiers cache the keys, but pick up rotations reasonably quickly
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.Auth.PublicJWKS())
+}
+
+// issueTokens generates an access token and a refresh token starting a new
+// token family for a user who just authenticated
+func (h *Handlers) issueTokens(user *models.User) (*models.LoginResponse, error) {
+	token, err := h.Auth.GenerateToken(user.ID, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	familyID, err := auth.GenerateTokenFamily()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(h.Auth.RefreshTokenExpiry())
+	if err := h.Tokens.CreateRefreshToken(user.ID, familyID, auth.HashRefreshToken(refreshToken), expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.Auth.TokenExpiry().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// usernamePattern matches 3-32 characters of letters, digits, '.', '_' or '-', starting with a letter
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]{2,31}$`)
+
+// Password policy
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// RegisterHandler handles user self-registration and returns an access token and a refresh token
+func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := validateUsername(req.Username); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create the user in the database
+	user, err := h.Users.CreateUser(req.Username, req.Password, models.RoleUser)
+	if errors.Is(err, db.ErrUsernameTaken) {
+		respondWithError(w, http.StatusConflict, "Username is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		return
+	}
+
+	// Generate the access and refresh tokens
+	response, err := h.issueTokens(user)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// Return the token
-	respondWithJSON(w, http.StatusOK, models.LoginResponse{Token: token})
+	// Return the tokens
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// validateUsername checks the username against the allowed format
+func validateUsername(username string) error {
+	if username == "" {
+		return errors.New("Username is required")
+	}
+	if !usernamePattern.MatchString(username) {
+		return errors.New("Username must be 3-32 characters long, start with a letter and contain only letters, digits, '.', '_' or '-'")
+	}
+	return nil
+}
+
+// validatePassword checks the password against the password policy and
+// reports every requirement it fails
+func validatePassword(password string) error {
+	if password == "" {
+		return errors.New("Password is required")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+
+	var problems []string
+	if len(password) < minPasswordLength {
+		problems = append(problems, fmt.Sprintf("be at least %d characters long", minPasswordLength))
+	}
+	if len(password) > maxPasswordLength {
+		problems = append(problems, fmt.Sprintf("be at most %d bytes long", maxPasswordLength))
+	}
+	if !hasLetter {
+		problems = append(problems, "contain at least one letter")
+	}
+	if !hasDigit {
+		problems = append(problems, "contain at least one digit")
+	}
+	if strings.TrimSpace(password) != password {
+		problems = append(problems, "not start or end with whitespace")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Password must " + strings.Join(problems, ", "))
+	}
+	return nil
 }
 
 // respondWithError responds with an error message
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// slugPattern matches 1-64 characters of lowercase letters, digits and single
+// hyphens between them, as in home-garden
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Length limits of categories
+const (
+	maxSlugLength         = 64
+	maxCategoryNameLength = 100
+)
+
+// CategoriesHandler handles retrieving the category tree, with the number of
+// products of every category
+func (h *Handlers) CategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.Categories.GetCategories()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving categories")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, categoryTree(categories))
+}
+
+// categoryTree arranges categories under their parents, siblings sorted by
+// name then slug, and totals the products of every subtree
+func categoryTree(categories []models.Category) []models.CategoryNode {
+	children := map[string][]models.Category{}
+	for _, category := range categories {
+		children[category.Parent] = append(children[category.Parent], category)
+	}
+
+	var build func(parent string) []models.CategoryNode
+	build = func(parent string) []models.CategoryNode {
+		siblings := children[parent]
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Name != siblings[j].Name {
+				return siblings[i].Name < siblings[j].Name
+			}
+			return siblings[i].Slug < siblings[j].Slug
+		})
+
+		nodes := make([]models.CategoryNode, len(siblings))
+		for i, category := range siblings {
+			nodes[i] = models.CategoryNode{Category: category, TotalProductCount: category.ProductCount, Children: build(category.Slug)}
+			for _, child := range nodes[i].Children {
+				nodes[i].TotalProductCount += child.TotalProductCount
+			}
+		}
+		return nodes
+	}
+	return build("")
+}
+
+// CreateCategoryHandler handles creating a category (editors and admins only)
+func (h *Handlers) CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if len(req.Slug) > maxSlugLength || !slugPattern.MatchString(req.Slug) {
+		respondWithError(w, http.StatusBadRequest, "Slug must be 1-64 lowercase letters, digits and hyphens, as in home-garden")
+		return
+	}
+	category := models.Category{Slug: req.Slug, Name: req.Name, Parent: req.Parent}
+	if err := validateCategory(&category); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create the category in the database
+	created, err := h.Categories.CreateCategory(category)
+	if errors.Is(err, db.ErrCategoryExists) {
+		respondWithError(w, http.StatusConflict, "Category already exists")
+		return
+	}
+	if errors.Is(err, db.ErrParentNotFound) {
+		respondWithError(w, http.StatusBadRequest, "Parent category not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating category")
+		return
+	}
+
+	// Return the created category
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdateCategoryHandler handles renaming a category and moving it under
+// another parent (editors and admins only)
+func (h *Handlers) UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	// Parse the request body
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Products refer to categories by slug, so slugs stay
+	if req.Slug != "" && req.Slug != slug {
+		respondWithError(w, http.StatusBadRequest, "Slug cannot be changed")
+		return
+	}
+
+	// Validate the request; categories created along with their products may
+	// have any slug
+	category := models.Category{Slug: slug, Name: req.Name, Parent: req.Parent}
+	if err := validateCategory(&category); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Update the category in the database
+	err := h.Categories.UpdateCategory(category)
+	if errors.Is(err, db.ErrCategoryNotFound) {
+		respondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if errors.Is(err, db.ErrParentNotFound) {
+		respondWithError(w, http.StatusBadRequest, "Parent category not found")
+		return
+	}
+	if errors.Is(err, db.ErrCategoryCycle) {
+		respondWithError(w, http.StatusBadRequest, "Category cannot be moved under itself or its subcategories")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating category")
+		return
+	}
+
+	// Return the updated category, along with its product count
+	updated, err := h.Categories.GetCategory(slug)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving category")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteCategoryHandler handles deleting a category without products or
+// subcategories (editors and admins only)
+func (h *Handlers) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	err := h.Categories.DeleteCategory(r.PathValue("slug"))
+	if errors.Is(err, db.ErrCategoryNotFound) {
+		respondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if errors.Is(err, db.ErrCategoryInUse) {
+		respondWithError(w, http.StatusConflict, "Category still has products or subcategories")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting category")
+		return
+	}
+
+	// Return success
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Category deleted successfully"})
+}
+
+// validateCategory trims and validates the name and parent of a category
+func validateCategory(category *models.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	category.Parent = strings.TrimSpace(category.Parent)
+
+	if category.Name == "" {
+		return errors.New("Name is required")
+	}
+	if utf8.RuneCountInString(category.Name) > maxCategoryNameLength {
+		return errors.New("Name must be at most 100 characters long")
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package handlers
+
+// Config configures the HTTP handlers
+type Config struct {
+	// DefaultPageSize is the page size of paginated responses when the request has no limit
+	DefaultPageSize int
+
+	// MaxPageSize is the largest limit a request may ask for
+	MaxPageSize int
+
+	// CursorKey signs pagination cursors; a random key is generated when it
+	// is empty, invalidating cursors on restart
+	CursorKey []byte
+}
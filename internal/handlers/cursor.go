@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+)
+
+// errInvalidCursor is returned for cursors that weren't issued by this server
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor turns a cursor into an opaque token: its JSON encoding and an
+// HMAC-SHA256 signature of it, both base64url-encoded
+func (h *Handlers) encodeCursor(cursor *db.ProductCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(h.signCursor(payload))
+}
+
+// decodeCursor verifies the signature of a cursor token and decodes it
+func (h *Handlers) decodeCursor(token string) (*db.ProductCursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, h.signCursor(payload)) {
+		return nil, errInvalidCursor
+	}
+
+	var cursor db.ProductCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// signCursor returns the signature of a cursor's JSON encoding
+func (h *Handlers) signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, h.Config.CursorKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// parseFacets parses the comma-separated facets a product listing counts
+// products by, as in category,price
+func parseFacets(value string) (category, price bool, err error) {
+	if value == "" {
+		return false, false, nil
+	}
+	for _, facet := range strings.Split(value, ",") {
+		switch strings.TrimSpace(facet) {
+		case "category":
+			category = true
+		case "price":
+			price = true
+		default:
+			return false, false, fmt.Errorf("Invalid facets: facet %q is unknown; products can be counted by category and price", strings.TrimSpace(facet))
+		}
+	}
+	return category, price, nil
+}
+
+// productFacets counts the products matching a query per category and per
+// price range, as requested. Each facet drops the query's filter on it, so
+// that the counts of the values not selected remain.
+func (h *Handlers) productFacets(query db.ProductQuery, category, price bool) (*models.Facets, error) {
+	facets := &models.Facets{}
+	if category {
+		q := query
+		q.Categories, q.Subcategories = nil, false
+		counts, err := h.Products.CountProductsByCategory(q)
+		if err != nil {
+			return nil, err
+		}
+		facets.Category = counts
+	}
+	if price {
+		q := query
+		q.MinPrice, q.MaxPrice = nil, nil
+		ranges, err := h.Products.CountProductsByPrice(q)
+		if err != nil {
+			return nil, err
+		}
+		facets.Price = ranges
+	}
+	return facets, nil
+}
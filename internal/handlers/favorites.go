@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/najwa/product-catalog-api/internal/db"
 	"github.com/najwa/product-catalog-api/internal/middleware"
@@ -10,13 +12,7 @@ import (
 )
 
 // AddFavoriteHandler handles adding a product to the user's favorites
-func AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
+func (h *Handlers) AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, ok := middleware.GetUserID(r)
 	if !ok {
@@ -38,7 +34,11 @@ func AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add the favorite to the database
-	err := db.AddFavorite(userID, req.ProductID, req.Notes)
+	err := h.Favorites.AddFavorite(userID, req.ProductID, req.Notes)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error adding favorite: "+err.Error())
 		return
@@ -48,14 +48,8 @@ func AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Favorite added successfully"})
 }
 
-// GetFavoritesHandler handles retrieving the user's favorite products
-func GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow GET method
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
+// GetFavoritesHandler handles retrieving the user's favorite products with pagination
+func (h *Handlers) GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, ok := middleware.GetUserID(r)
 	if !ok {
@@ -63,13 +57,62 @@ func GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parse pagination parameters
+	page, limit, err := parsePagination(r.URL.Query(), h.Config.MaxPageSize)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = h.Config.DefaultPageSize
+	}
+
 	// Get the favorites from the database
-	favorites, err := db.GetFavorites(userID)
+	favorites, total, err := h.Favorites.GetFavorites(userID, page, limit)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error retrieving favorites")
 		return
 	}
 
 	// Return the favorites
-	respondWithJSON(w, http.StatusOK, favorites)
+	response := models.PaginatedResponse{
+		Total:   &total,
+		Page:    page,
+		Limit:   limit,
+		HasNext: page*limit < total,
+		Results: favorites,
+	}
+	paginate(w, r, &response)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// RemoveFavoriteHandler handles removing a product from the user's favorites
+func (h *Handlers) RemoveFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the product ID from the path
+	productID, err := strconv.Atoi(r.PathValue("productId"))
+	if err != nil || productID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	// Remove the favorite from the database
+	err = h.Favorites.RemoveFavorite(userID, productID)
+	if errors.Is(err, db.ErrFavoriteNotFound) {
+		respondWithError(w, http.StatusNotFound, "Favorite not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing favorite")
+		return
+	}
+
+	// Return success
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Favorite removed successfully"})
 }
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/revocation"
+)
+
+// Handlers serves the API from the given stores
+type Handlers struct {
+	Products    db.ProductStore
+	Categories  db.CategoryStore
+	Users       db.UserStore
+	Favorites   db.FavoriteStore
+	Tokens      db.TokenStore
+	Auth        *auth.Issuer
+	Revocations *revocation.Revoker
+	Config      Config
+}
+
+// New creates handlers serving every route from a single store, issuing and
+// validating tokens with issuer
+func New(store db.Store, issuer *auth.Issuer, revocations *revocation.Revoker, cfg Config) *Handlers {
+	if cfg.MaxPageSize <= 0 {
+		cfg.MaxPageSize = 100
+	}
+	if cfg.DefaultPageSize <= 0 {
+		cfg.DefaultPageSize = min(10, cfg.MaxPageSize)
+	}
+	if cfg.DefaultPageSize > cfg.MaxPageSize {
+		cfg.DefaultPageSize = cfg.MaxPageSize
+	}
+	if len(cfg.CursorKey) == 0 {
+		cfg.CursorKey = make([]byte, 32)
+		if _, err := rand.Read(cfg.CursorKey); err != nil {
+			panic(fmt.Sprintf("error generating cursor key: %v", err))
+		}
+	}
+
+	return &Handlers{
+		Products:    store,
+		Categories:  store,
+		Users:       store,
+		Favorites:   store,
+		Tokens:      store,
+		Auth:        issuer,
+		Revocations: revocations,
+		Config:      cfg,
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// parsePagination parses the page and limit parameters of a listing. The
+// page is 1 and the limit 0 when not given.
+func parsePagination(values url.Values, maxLimit int) (page, limit int, err error) {
+	page = 1
+	if value := values.Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return 0, 0, errors.New("Page must be a positive integer")
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return 0, 0, errors.New("Limit must be a positive integer")
+		}
+		if limit > maxLimit {
+			return 0, 0, fmt.Errorf("Limit must be at most %d", maxLimit)
+		}
+	}
+
+	// The offsets of the page must fit in an int
+	if page > math.MaxInt/max(limit, maxLimit) {
+		return 0, 0, errors.New("Page is out of range")
+	}
+	return page, limit, nil
+}
+
+// paginate completes a page of results with the number of pages, if they
+// were counted, and sets its Link header pointing to the first, previous,
+// next and last pages, as in RFC 8288. Pages requested by cursor only link
+// to the first and next pages.
+func paginate(w http.ResponseWriter, r *http.Request, response *models.PaginatedResponse) {
+	links := []string{}
+	link := func(rel string, set map[string]string) {
+		values := r.URL.Query()
+		values.Del("cursor")
+		values.Del("page")
+		for name, value := range set {
+			values.Set(name, value)
+		}
+		target := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel))
+	}
+
+	if response.Total == nil {
+		link("first", nil)
+		if response.NextCursor != "" {
+			link("next", map[string]string{"cursor": response.NextCursor})
+		}
+	} else {
+		totalPages := (*response.Total + response.Limit - 1) / response.Limit
+		response.TotalPages = &totalPages
+
+		last := max(totalPages, 1)
+		link("first", map[string]string{"page": "1"})
+		if response.Page > 1 {
+			link("prev", map[string]string{"page": strconv.Itoa(min(response.Page-1, last))})
+		}
+		if response.HasNext {
+			link("next", map[string]string{"page": strconv.Itoa(response.Page + 1)})
+		}
+		link("last", map[string]string{"page": strconv.Itoa(last)})
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
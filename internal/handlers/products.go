@@ -1,52 +1,440 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/najwa/product-catalog-api/internal/db"
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
-// ProductsHandler handles product listing with filtering, sorting, and pagination
-func ProductsHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow GET method
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+// ProductsHandler handles product listing with filtering, sorting, and pagination.
+// Pages are selected either by number or by the cursor returned with the
+// previous page; cursor pages skip the total count. Facet counts are added
+// on request.
+func (h *Handlers) ProductsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	values := r.URL.Query()
+	query, page, err := parseProductQuery(values, h.Config.MaxPageSize)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = h.Config.DefaultPageSize
+	}
+	categoryFacet, priceFacet, err := parseFacets(values.Get("facets"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Parse query parameters
-	query := r.URL.Query()
-	
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(query.Get("page"))
-	limit, _ := strconv.Atoi(query.Get("limit"))
-	
-	if page <= 0 {
-		page = 1
-	}
-	if limit <= 0 {
-		limit = 10
-	}
-	
-	// Parse filtering parameters
-	category := query.Get("category")
-	sort := query.Get("sort")
-	search := query.Get("search")
-	
-	// Get products from the database
-	products, total, err := db.GetProducts(page, limit, category, sort, search)
+	if token := values.Get("cursor"); token != "" {
+		if values.Get("page") != "" {
+			respondWithError(w, http.StatusBadRequest, "Page and cursor cannot be used together")
+			return
+		}
+		if !query.SupportsCursor() {
+			respondWithError(w, http.StatusBadRequest, "Cursors are not supported for searches sorted by relevance")
+			return
+		}
+		cursor, err := h.decodeCursor(token)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		if cursor.Sort != query.Sort.String() {
+			respondWithError(w, http.StatusBadRequest, "Cursor was issued for a different sort")
+			return
+		}
+		query.After = cursor
+	} else {
+		query.Offset = (page - 1) * query.Limit
+	}
+
+	// Get one product more than the page holds to know whether another follows
+	limit := query.Limit
+	query.Limit++
+	products, err := h.Products.GetProducts(query)
+	query.Limit = limit
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error retrieving products")
 		return
 	}
-	
+
+	response := models.PaginatedResponse{Limit: limit, HasNext: len(products) > limit}
+	if response.HasNext {
+		products = products[:limit]
+		if query.SupportsCursor() {
+			response.NextCursor = h.encodeCursor(query.CursorAfter(products[limit-1]))
+		}
+	}
+	response.Results = products
+
+	if query.After == nil {
+		total, err := h.Products.CountProducts(query)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving products")
+			return
+		}
+		response.Total = &total
+		response.Page = page
+	}
+
+	// Count the matching products per category and price range, if requested
+	if categoryFacet || priceFacet {
+		response.Facets, err = h.productFacets(query, categoryFacet, priceFacet)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving products")
+			return
+		}
+	}
+
 	// Return the products
-	respondWithJSON(w, http.StatusOK, models.PaginatedResponse{
-		Total:   total,
-		Page:    page,
-		Limit:   limit,
-		Results: products,
-	})
+	paginate(w, r, &response)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// attributePrefix starts the query parameters filtering on product
+// attributes, as in attr.color=red
+const attributePrefix = "attr."
+
+// parseProductQuery parses the pagination, filtering and sorting parameters
+// of a product listing, returning the page number separately. The limit is
+// left at 0 when not given, and may not exceed maxLimit.
+func parseProductQuery(values url.Values, maxLimit int) (db.ProductQuery, int, error) {
+	query := db.ProductQuery{Search: values.Get("search")}
+
+	// Parse pagination parameters
+	page, limit, err := parsePagination(values, maxLimit)
+	if err != nil {
+		return query, page, err
+	}
+	query.Limit = limit
+
+	// Parse sorting parameters
+	if query.Sort, err = db.ParseSort(values.Get("sort")); err != nil {
+		return query, page, fmt.Errorf("Invalid sort: %w", err)
+	}
+
+	// Parse filtering parameters; products of any of the categories match
+	for _, category := range values["category"] {
+		if category = strings.TrimSpace(category); category != "" {
+			query.Categories = append(query.Categories, category)
+		}
+	}
+
+	if query.MinPrice, err = parsePrice(values, "min_price"); err != nil {
+		return query, page, err
+	}
+	if query.MaxPrice, err = parsePrice(values, "max_price"); err != nil {
+		return query, page, err
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return query, page, errors.New("min_price must be less than or equal to max_price")
+	}
+
+	if value := values.Get("include_subcategories"); value != "" {
+		query.Subcategories, err = strconv.ParseBool(value)
+		if err != nil {
+			return query, page, errors.New("include_subcategories must be true or false")
+		}
+	}
+
+	if value := values.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, page, errors.New("in_stock must be true or false")
+		}
+		query.InStock = inStock
+	}
+
+	// Products must have one of the values given for every attribute
+	for key, attributeValues := range values {
+		name, ok := strings.CutPrefix(key, attributePrefix)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			return query, page, errors.New("Attribute filters must name the attribute, as in attr.color=red")
+		}
+		if query.Attributes == nil {
+			query.Attributes = map[string][]string{}
+		}
+		query.Attributes[name] = attributeValues
+	}
+
+	return query, page, nil
+}
+
+// parsePrice parses an optional price bound of a product listing
+func parsePrice(values url.Values, name string) (*float64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+		return nil, fmt.Errorf("%s must be a number greater than or equal to 0", name)
+	}
+	return &price, nil
+}
+
+// ProductHandler handles retrieving a single product by ID
+func (h *Handlers) ProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the product ID from the path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	// Get the product from the database
+	product, err := h.Products.GetProductByID(id)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving product")
+		return
+	}
+
+	// Return the product
+	respondWithJSON(w, http.StatusOK, product)
+}
+
+// CreateProductHandler handles creating a new product (editors and admins only)
+func (h *Handlers) CreateProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req models.ProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	product := models.Product{
+		Title:       req.Title,
+		Description: req.Description,
+		Price:       req.Price,
+		Category:    req.Category,
+		Image:       req.Image,
+		Stock:       req.Stock,
+		Rating:      req.Rating,
+		Attributes:  req.Attributes,
+	}
+
+	// Validate the request
+	if err := validateProduct(&product); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create the product in the database
+	created, err := h.Products.CreateProduct(product)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating product")
+		return
+	}
+
+	// Return the created product
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdateProductHandler handles replacing an existing product (editors and admins only)
+func (h *Handlers) UpdateProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the product ID from the path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	// Parse the request body
+	var req models.ProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	product := models.Product{
+		ID:          id,
+		Title:       req.Title,
+		Description: req.Description,
+		Price:       req.Price,
+		Category:    req.Category,
+		Image:       req.Image,
+		Stock:       req.Stock,
+		Rating:      req.Rating,
+		Attributes:  req.Attributes,
+	}
+
+	// Validate the request
+	if err := validateProduct(&product); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Update the product in the database
+	err = h.Products.UpdateProduct(product)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating product")
+		return
+	}
+
+	// Return the updated product, along with its popularity
+	updated, err := h.Products.GetProductByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving product")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+// PatchProductHandler handles partially updating an existing product (editors and admins only)
+func (h *Handlers) PatchProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the product ID from the path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	// Parse the request body
+	var req models.ProductPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get the current product from the database
+	product, err := h.Products.GetProductByID(id)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving product")
+		return
+	}
+
+	// Apply the provided fields
+	if req.Title != nil {
+		product.Title = *req.Title
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	if req.Price != nil {
+		product.Price = *req.Price
+	}
+	if req.Category != nil {
+		product.Category = *req.Category
+	}
+	if req.Image != nil {
+		product.Image = *req.Image
+	}
+	if req.Stock != nil {
+		product.Stock = *req.Stock
+	}
+	if req.Rating != nil {
+		product.Rating = *req.Rating
+	}
+	if req.Attributes != nil {
+		product.Attributes = req.Attributes
+	}
+
+	// Validate the resulting product
+	if err := validateProduct(product); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Update the product in the database
+	err = h.Products.UpdateProduct(*product)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating product")
+		return
+	}
+
+	// Return the updated product
+	respondWithJSON(w, http.StatusOK, product)
+}
+
+// DeleteProductHandler handles deleting a product (editors and admins only)
+func (h *Handlers) DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the product ID from the path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	// Delete the product from the database
+	err = h.Products.DeleteProduct(id)
+	if errors.Is(err, db.ErrProductNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting product")
+		return
+	}
+
+	// Return success
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Product deleted successfully"})
+}
+
+// validateProduct trims and validates the editable fields of a product
+func validateProduct(product *models.Product) error {
+	product.Title = strings.TrimSpace(product.Title)
+	product.Description = strings.TrimSpace(product.Description)
+	product.Category = strings.TrimSpace(product.Category)
+	product.Image = strings.TrimSpace(product.Image)
+
+	if product.Title == "" {
+		return errors.New("Title is required")
+	}
+	if product.Price < 0 {
+		return errors.New("Price must be greater than or equal to 0")
+	}
+	if product.Stock < 0 {
+		return errors.New("Stock must be greater than or equal to 0")
+	}
+	if product.Rating < 0 || product.Rating > 5 {
+		return errors.New("Rating must be between 0 and 5")
+	}
+	if product.Category == "" {
+		return errors.New("Category is required")
+	}
+	if product.Image == "" {
+		return errors.New("Image is required")
+	}
+
+	for name := range product.Attributes {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("Attribute names must not be empty")
+		}
+	}
+
+	u, err := url.ParseRequestURI(product.Image)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Image must be a valid http or https URL")
+	}
+
+	return nil
 }
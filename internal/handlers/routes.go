@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/najwa/product-catalog-api/internal/middleware"
+	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/router"
+)
+
+// Router returns a router serving all API routes
+func (h *Handlers) Router() http.Handler {
+	r := router.New()
+
+	// Public routes
+	r.HandleFunc(http.MethodPost, "/login", h.LoginHandler)
+	r.HandleFunc(http.MethodPost, "/register", h.RegisterHandler)
+	r.HandleFunc(http.MethodPost, "/token/refresh", h.RefreshTokenHandler)
+	r.HandleFunc(http.MethodGet, "/.well-known/jwks.json", h.JWKSHandler)
+	r.HandleFunc(http.MethodGet, "/products", h.ProductsHandler)
+	r.HandleFunc(http.MethodGet, "/products/{id}", h.ProductHandler)
+	r.HandleFunc(http.MethodGet, "/categories", h.CategoriesHandler)
+
+	// Protected routes
+	authenticated := r.With(middleware.Authenticate(h.Auth, h.Revocations))
+	authenticated.HandleFunc(http.MethodPost, "/logout", h.LogoutHandler)
+	authenticated.HandleFunc(http.MethodPost, "/favorites", h.AddFavoriteHandler)
+	authenticated.HandleFunc(http.MethodGet, "/favorites", h.GetFavoritesHandler)
+	authenticated.HandleFunc(http.MethodDelete, "/favorites/{productId}", h.RemoveFavoriteHandler)
+
+	// Catalog writes are restricted to catalog editors and admins
+	editors := authenticated.With(middleware.RequireRole(models.RoleEditor, models.RoleAdmin))
+	editors.HandleFunc(http.MethodPost, "/products", h.CreateProductHandler)
+	editors.HandleFunc(http.MethodPut, "/products/{id}", h.UpdateProductHandler)
+	editors.HandleFunc(http.MethodPatch, "/products/{id}", h.PatchProductHandler)
+	editors.HandleFunc(http.MethodDelete, "/products/{id}", h.DeleteProductHandler)
+	editors.HandleFunc(http.MethodPost, "/categories", h.CreateCategoryHandler)
+	editors.HandleFunc(http.MethodPut, "/categories/{slug}", h.UpdateCategoryHandler)
+	editors.HandleFunc(http.MethodDelete, "/categories/{slug}", h.DeleteCategoryHandler)
+
+	return r
+}
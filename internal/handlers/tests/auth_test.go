@@ -0,0 +1,576 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/handlers"
+	"github.com/najwa/product-catalog-api/internal/middleware"
+	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/password"
+	"github.com/najwa/product-catalog-api/internal/revocation"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	h, _ := newTestHandlers(t)
+
+	testCases := []struct {
+		name           string
+		username       string
+		password       string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Valid registration",
+			username:       "jane.doe",
+			password:       "secret123",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Duplicate username",
+			username:       "jane.doe",
+			password:       "secret123",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Username too short",
+			username:       "jd",
+			password:       "secret123",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Username must be 3-32 characters long",
+		},
+		{
+			name:           "Username with invalid characters",
+			username:       "jane doe!",
+			password:       "secret123",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Username must be 3-32 characters long",
+		},
+		{
+			name:           "Password too short and without digit",
+			username:       "janet",
+			password:       "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Password must be at least 8 characters long, contain at least one digit",
+		},
+		{
+			name:           "Password without letter",
+			username:       "janet",
+			password:       "12345678",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Password must contain at least one letter",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(models.RegisterRequest{Username: tc.username, Password: tc.password})
+			if err != nil {
+				t.Fatalf("Error marshaling request body: %v", err)
+			}
+
+			req, err := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatalf("Error creating request: %v", err)
+			}
+
+			rr := executeRequest(req, http.HandlerFunc(h.RegisterHandler))
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+
+			if tc.expectedError != "" {
+				var response models.ErrorResponse
+				if err := parseResponse(rr, &response); err != nil {
+					t.Fatalf("Error unmarshaling response: %v", err)
+				}
+				if !strings.HasPrefix(response.Error, tc.expectedError) {
+					t.Errorf("Expected error starting with %q, got %q", tc.expectedError, response.Error)
+				}
+			}
+
+			if tc.expectedStatus == http.StatusCreated {
+				var response models.LoginResponse
+				if err := parseResponse(rr, &response); err != nil {
+					t.Fatalf("Error unmarshaling response: %v", err)
+				}
+				claims, err := h.Auth.ValidateToken(response.Token)
+				if err != nil {
+					t.Fatalf("Error validating returned token: %v", err)
+				}
+				if claims.Role != models.RoleUser {
+					t.Errorf("Expected role %q, got %q", models.RoleUser, claims.Role)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	h, store := newTestHandlersAt(t, dbPath)
+
+	// Store a user with a legacy unsalted SHA-256 hash of "1234", bypassing
+	// the store which would hash the password with the default hasher
+	legacyHash := "03ac674216f3e15c761ee1a5e255f067953623c8b388b4459e13f978d7c846f4"
+	conn, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer conn.Close()
+	_, err = conn.Exec("INSERT INTO users (username, password, role) VALUES (?, ?, ?)", "legacy", legacyHash, models.RoleUser)
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+
+	login := func(username, password string) int {
+		body, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+		req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		return executeRequest(req, http.HandlerFunc(h.LoginHandler)).Code
+	}
+
+	storedHash := func() string {
+		user, err := store.GetUserByUsername("legacy")
+		if err != nil {
+			t.Fatalf("Error getting user: %v", err)
+		}
+		return user.Password
+	}
+
+	t.Run("Wrong password", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, login("legacy", "wrong"))
+		if storedHash() != legacyHash {
+			t.Errorf("Hash should not change after a failed login")
+		}
+	})
+
+	t.Run("Legacy hash is upgraded on login", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, login("legacy", "1234"))
+
+		hash := storedHash()
+		if !strings.HasPrefix(hash, "$"+password.Default().ID()+"$") {
+			t.Errorf("Expected hash to be upgraded to %s, got %q", password.Default().ID(), hash)
+		}
+
+		// The upgraded hash must keep working
+		checkResponseCode(t, http.StatusOK, login("legacy", "1234"))
+		if storedHash() != hash {
+			t.Errorf("Up-to-date hash should not be rehashed")
+		}
+	})
+}
+
+// countingHasher counts the passwords verified by the hasher it wraps
+type countingHasher struct {
+	password.Hasher
+	verified atomic.Int32
+}
+
+func (h *countingHasher) Verify(plaintext, encoded string) (bool, error) {
+	h.verified.Add(1)
+	return h.Hasher.Verify(plaintext, encoded)
+}
+
+// TestLoginUnknownUser checks that logins of unknown users verify a password
+// just like logins with a wrong password, so that both take as long
+func TestLoginUnknownUser(t *testing.T) {
+	t.Parallel()
+
+	hasher := &countingHasher{Hasher: password.NewBcrypt(4)}
+	store := db.NewMemoryStore(db.Options{Hasher: hasher})
+	issuer, err := auth.NewIssuer(auth.Config{})
+	if err != nil {
+		t.Fatalf("Error initializing authentication: %v", err)
+	}
+	h := handlers.New(store, issuer, nil, handlers.Config{})
+
+	body, _ := json.Marshal(models.LoginRequest{Username: "nobody", Password: "secret123"})
+	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, http.HandlerFunc(h.LoginHandler)).Code)
+	if verified := hasher.verified.Load(); verified != 1 {
+		t.Errorf("Expected a password to be verified for an unknown user, got %d verifications", verified)
+	}
+}
+
+func TestRefreshTokenHandler(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	h, store := newTestHandlers(t)
+
+	user, err := store.CreateUser("refresher", "secret123", models.RoleUser)
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+
+	// Log in to obtain the first refresh token
+	body, _ := json.Marshal(models.LoginRequest{Username: "refresher", Password: "secret123"})
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	rr := executeRequest(req, http.HandlerFunc(h.LoginHandler))
+	checkResponseCode(t, http.StatusOK, rr.Code)
+
+	var login models.LoginResponse
+	if err := parseResponse(rr, &login); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if login.RefreshToken == "" || login.TokenType != "Bearer" || login.ExpiresIn <= 0 {
+		t.Fatalf("Expected a refresh token and access token metadata, got %+v", login)
+	}
+
+	refresh := func(token string) (*httptest.ResponseRecorder, models.LoginResponse) {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+		req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		rr := executeRequest(req, http.HandlerFunc(h.RefreshTokenHandler))
+
+		var response models.LoginResponse
+		parseResponse(rr, &response)
+		return rr, response
+	}
+
+	var rotated models.LoginResponse
+
+	t.Run("Refresh rotates the token", func(t *testing.T) {
+		var rr *httptest.ResponseRecorder
+		rr, rotated = refresh(login.RefreshToken)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+			t.Errorf("Expected a new refresh token")
+		}
+		if _, err := h.Auth.ValidateToken(rotated.Token); err != nil {
+			t.Errorf("Expected a valid access token: %v", err)
+		}
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		rr, _ := refresh("not-a-token")
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Reuse revokes the family", func(t *testing.T) {
+		// Replaying the already rotated token is rejected...
+		rr, _ := refresh(login.RefreshToken)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+
+		// ...and invalidates the token it was rotated into
+		rr, _ = refresh(rotated.RefreshToken)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		err := store.CreateRefreshToken(user.ID, "expired", auth.HashRefreshToken("expired-token"), time.Now().Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("Error creating refresh token: %v", err)
+		}
+
+		rr, _ := refresh("expired-token")
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestLogoutHandler(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	h, store := newTestHandlers(t)
+
+	store.CreateUser("leaver", "secret123", models.RoleUser)
+
+	// login starts a new session and returns its tokens
+	login := func() models.LoginResponse {
+		body, _ := json.Marshal(models.LoginRequest{Username: "leaver", Password: "secret123"})
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+		rr := executeRequest(req, http.HandlerFunc(h.LoginHandler))
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var response models.LoginResponse
+		if err := parseResponse(rr, &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		return response
+	}
+
+	// protected returns the status of a request to a protected route
+	protected := func(token string) int {
+		req, _ := http.NewRequest("GET", "/favorites", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.GetFavoritesHandler))).Code
+	}
+
+	// refresh returns the status of a refresh token exchange
+	refresh := func(token string) int {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+		req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(body))
+		return executeRequest(req, http.HandlerFunc(h.RefreshTokenHandler)).Code
+	}
+
+	// logout logs out with the given access token and request body
+	logout := func(token string, body models.LogoutRequest) int {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/logout", bytes.NewBuffer(b))
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.LogoutHandler))).Code
+	}
+
+	first := login()
+	second := login()
+
+	t.Run("Logout revokes the session", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, logout(first.Token, models.LogoutRequest{RefreshToken: first.RefreshToken}))
+
+		checkResponseCode(t, http.StatusUnauthorized, protected(first.Token))
+		checkResponseCode(t, http.StatusUnauthorized, refresh(first.RefreshToken))
+
+		// Other sessions are unaffected
+		checkResponseCode(t, http.StatusOK, protected(second.Token))
+	})
+
+	t.Run("Revocations survive a restart", func(t *testing.T) {
+		h.Revocations.Close()
+		revocations, err := revocation.New(store, store, h.Auth.TokenExpiry())
+		if err != nil {
+			t.Fatalf("Error initializing revocation: %v", err)
+		}
+		h.Revocations = revocations
+
+		checkResponseCode(t, http.StatusUnauthorized, protected(first.Token))
+	})
+
+	t.Run("Logouts of other instances are seen after a refresh", func(t *testing.T) {
+		other, err := revocation.New(store, store, h.Auth.TokenExpiry())
+		if err != nil {
+			t.Fatalf("Error initializing revocation: %v", err)
+		}
+		defer other.Close()
+
+		session := login()
+		claims, err := h.Auth.ValidateToken(session.Token)
+		if err != nil {
+			t.Fatalf("Error validating token: %v", err)
+		}
+		checkResponseCode(t, http.StatusOK, logout(session.Token, models.LogoutRequest{RefreshToken: session.RefreshToken}))
+
+		if err := other.Refresh(); err != nil {
+			t.Fatalf("Error refreshing revocations: %v", err)
+		}
+		if revoked, err := other.IsRevoked(claims); err != nil || !revoked {
+			t.Errorf("Expected token to be revoked on the other instance, got revoked=%v err=%v", revoked, err)
+		}
+	})
+
+	t.Run("Logout of all sessions", func(t *testing.T) {
+		third := login()
+
+		// Another instance sharing the database, which has seen the tokens
+		other, err := revocation.New(store, store, h.Auth.TokenExpiry())
+		if err != nil {
+			t.Fatalf("Error initializing revocation: %v", err)
+		}
+		defer other.Close()
+		claims, err := h.Auth.ValidateToken(third.Token)
+		if err != nil {
+			t.Fatalf("Error validating token: %v", err)
+		}
+		if revoked, err := other.IsRevoked(claims); err != nil || revoked {
+			t.Fatalf("Expected token to be valid on the other instance, got revoked=%v err=%v", revoked, err)
+		}
+
+		checkResponseCode(t, http.StatusOK, logout(second.Token, models.LogoutRequest{AllSessions: true}))
+
+		// The other instance sees the logout once it refreshes
+		if err := other.Refresh(); err != nil {
+			t.Fatalf("Error refreshing revocations: %v", err)
+		}
+		if revoked, err := other.IsRevoked(claims); err != nil || !revoked {
+			t.Errorf("Expected token to be revoked on the other instance, got revoked=%v err=%v", revoked, err)
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, protected(second.Token))
+		checkResponseCode(t, http.StatusUnauthorized, protected(third.Token))
+		checkResponseCode(t, http.StatusUnauthorized, refresh(second.RefreshToken))
+		checkResponseCode(t, http.StatusUnauthorized, refresh(third.RefreshToken))
+
+		// Logging in again starts a valid session
+		checkResponseCode(t, http.StatusOK, protected(login().Token))
+	})
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	// Set up handlers with signing keys of their own
+	h, _ := newTestHandlers(t)
+	dir := t.TempDir()
+
+	// writeFile writes a key file into the test directory and returns its path
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("Error writing %s: %v", name, err)
+		}
+		return path
+	}
+
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating Ed25519 key: %v", err)
+	}
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edPrivate)
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+	rsaDER, _ := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating ECDSA key: %v", err)
+	}
+	ecDER, _ := x509.MarshalECPrivateKey(ecPrivate)
+	ecPublicDER, _ := x509.MarshalPKIXPublicKey(&ecPrivate.PublicKey)
+	ecFile := writeFile("ec.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+	ecPublicFile := writeFile("ec.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPublicDER}))
+
+	hmacKey := auth.KeyConfig{ID: "hs-2024", Algorithm: "HS256", SecretFile: writeFile("secret", []byte(strings.Repeat("s", 32)+"\n"))}
+	edKey := auth.KeyConfig{ID: "ed-2025", Algorithm: "EdDSA", PrivateKeyFile: writeFile("ed.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}))}
+	rsaKey := auth.KeyConfig{ID: "rsa-external", Algorithm: "RS256", PublicKeyFile: writeFile("rsa.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaDER}))}
+
+	useKeys := func(cfg auth.KeysConfig) {
+		ks, err := auth.NewKeySet(cfg)
+		if err != nil {
+			t.Fatalf("Error loading key set: %v", err)
+		}
+		h.Auth.SetKeySet(ks)
+	}
+
+	// Issue a token with the original HMAC key
+	useKeys(auth.KeysConfig{Active: "hs-2024", Keys: []auth.KeyConfig{hmacKey}})
+	oldToken, err := h.Auth.GenerateToken(1, models.RoleUser, 0)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+
+	t.Run("Tokens of the previous key stay valid after rotation", func(t *testing.T) {
+		useKeys(auth.KeysConfig{Active: "ed-2025", Keys: []auth.KeyConfig{hmacKey, edKey, rsaKey}})
+
+		newToken, err := h.Auth.GenerateToken(1, models.RoleUser, 0)
+		if err != nil {
+			t.Fatalf("Error generating token: %v", err)
+		}
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &auth.Claims{})
+		if err != nil {
+			t.Fatalf("Error parsing token: %v", err)
+		}
+		if parsed.Header["kid"] != "ed-2025" || parsed.Header["alg"] != "EdDSA" {
+			t.Errorf("Expected token signed by ed-2025 with EdDSA, got header %v", parsed.Header)
+		}
+
+		for _, token := range []string{oldToken, newToken} {
+			if _, err := h.Auth.ValidateToken(token); err != nil {
+				t.Errorf("Expected token to be valid: %v", err)
+			}
+		}
+	})
+
+	t.Run("Tokens of a removed key are rejected", func(t *testing.T) {
+		useKeys(auth.KeysConfig{Active: "ed-2025", Keys: []auth.KeyConfig{edKey}})
+
+		if _, err := h.Auth.ValidateToken(oldToken); err == nil {
+			t.Errorf("Expected token signed by a removed key to be rejected")
+		}
+	})
+
+	t.Run("Algorithm must match the key", func(t *testing.T) {
+		// An HMAC token claiming to be signed by the Ed25519 key
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{UserID: 1})
+		forged.Header["kid"] = "ed-2025"
+		tokenString, _ := forged.SignedString([]byte(strings.Repeat("x", 32)))
+
+		if _, err := h.Auth.ValidateToken(tokenString); err == nil {
+			t.Errorf("Expected token with a mismatched algorithm to be rejected")
+		}
+	})
+
+	t.Run("JWKS publishes only public keys", func(t *testing.T) {
+		useKeys(auth.KeysConfig{Active: "ed-2025", Keys: []auth.KeyConfig{hmacKey, edKey, rsaKey}})
+
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		rr := executeRequest(req, http.HandlerFunc(h.JWKSHandler))
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var jwks auth.JWKS
+		if err := parseResponse(rr, &jwks); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if len(jwks.Keys) != 2 {
+			t.Fatalf("Expected 2 public keys, got %d", len(jwks.Keys))
+		}
+		if jwks.Keys[0].KeyID != "ed-2025" || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].X == "" {
+			t.Errorf("Unexpected Ed25519 key: %+v", jwks.Keys[0])
+		}
+		if jwks.Keys[1].KeyID != "rsa-external" || jwks.Keys[1].KeyType != "RSA" || jwks.Keys[1].N == "" || jwks.Keys[1].E != "AQAB" {
+			t.Errorf("Unexpected RSA key: %+v", jwks.Keys[1])
+		}
+	})
+
+	t.Run("ECDSA key matching its algorithm", func(t *testing.T) {
+		cfg := auth.KeysConfig{Active: "es-2025", Keys: []auth.KeyConfig{
+			{ID: "es-2025", Algorithm: "ES256", PrivateKeyFile: ecFile},
+			{ID: "es-external", Algorithm: "ES256", PublicKeyFile: ecPublicFile},
+		}}
+		if _, err := auth.NewKeySet(cfg); err != nil {
+			t.Errorf("Expected P-256 keys to be accepted for ES256, got %v", err)
+		}
+	})
+
+	t.Run("Invalid configurations", func(t *testing.T) {
+		invalid := []auth.KeysConfig{
+			{Active: "missing", Keys: []auth.KeyConfig{hmacKey}},
+			{Active: "rsa-external", Keys: []auth.KeyConfig{rsaKey}},
+			{Active: "short", Keys: []auth.KeyConfig{{ID: "short", Algorithm: "HS256", Secret: "too-short"}}},
+			{Active: "none", Keys: []auth.KeyConfig{{ID: "none", Algorithm: "none"}}},
+			{Active: "hs-2024", Keys: []auth.KeyConfig{hmacKey, hmacKey}},
+			{Active: "es", Keys: []auth.KeyConfig{{ID: "es", Algorithm: "ES384", PrivateKeyFile: ecFile}}},
+			{Active: "hs-2024", Keys: []auth.KeyConfig{hmacKey, {ID: "es", Algorithm: "ES512", PublicKeyFile: ecPublicFile}}},
+		}
+		for _, cfg := range invalid {
+			if _, err := auth.NewKeySet(cfg); err == nil {
+				t.Errorf("Expected an error for key set %+v", cfg)
+			}
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+func TestCategoriesHandlers(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database; the products create the electronics and clothing
+	// categories
+	h, store := newTestHandlers(t)
+	seedTestProducts(store)
+
+	// Create a catalog editor and a regular user and generate their tokens
+	tokens := map[string]string{}
+	for _, role := range []string{models.RoleEditor, models.RoleUser} {
+		user, err := store.CreateUser("test"+role, "password", role)
+		if err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+		tokens[role], err = h.Auth.GenerateToken(user.ID, user.Role, user.TokenVersion)
+		if err != nil {
+			t.Fatalf("Error generating token: %v", err)
+		}
+	}
+	editorToken, userToken := tokens[models.RoleEditor], tokens[models.RoleUser]
+
+	router := h.Router()
+
+	// newRequest builds a request with a JSON body and an optional bearer token
+	newRequest := func(method, url, token string, body interface{}) *http.Request {
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatalf("Error marshaling request body: %v", err)
+			}
+		}
+		req, err := http.NewRequest(method, url, &buf)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req
+	}
+
+	t.Run("Create category", func(t *testing.T) {
+		phones := models.CategoryRequest{Slug: "phones", Name: " Phones ", Parent: "electronics"}
+		rr := executeRequest(newRequest("POST", "/categories", editorToken, phones), router)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var created models.Category
+		if err := parseResponse(rr, &created); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if created.ID == 0 || created.Slug != "phones" || created.Name != "Phones" || created.Parent != "electronics" {
+			t.Errorf("Unexpected created category: %+v", created)
+		}
+
+		rr = executeRequest(newRequest("POST", "/categories", userToken, phones), router)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+		rr = executeRequest(newRequest("POST", "/categories", editorToken, phones), router)
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("Create category validation", func(t *testing.T) {
+		invalid := []models.CategoryRequest{
+			{Slug: "", Name: "Garden"},
+			{Slug: "Garden", Name: "Garden"},
+			{Slug: "home--garden", Name: "Home & Garden"},
+			{Slug: "garden", Name: " "},
+			{Slug: "garden", Name: "Garden", Parent: "outdoors"},
+		}
+		for _, body := range invalid {
+			rr := executeRequest(newRequest("POST", "/categories", editorToken, body), router)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Category tree", func(t *testing.T) {
+		_, err := store.CreateProduct(models.Product{Title: "Smartphone Mini", Price: 399, Category: "phones", Image: "https://example.com/mini.jpg"})
+		if err != nil {
+			t.Fatalf("Error creating product: %v", err)
+		}
+
+		rr := executeRequest(newRequest("GET", "/categories", "", nil), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var tree []models.CategoryNode
+		if err := parseResponse(rr, &tree); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if len(tree) != 2 || tree[0].Slug != "clothing" || tree[0].TotalProductCount != 1 || len(tree[0].Children) != 0 {
+			t.Fatalf("Expected clothing with 1 product first, got %+v", tree)
+		}
+		electronics := tree[1]
+		if electronics.Slug != "electronics" || electronics.ProductCount != 2 || electronics.TotalProductCount != 3 {
+			t.Errorf("Expected electronics with 2 products of its own and 3 in all, got %+v", electronics)
+		}
+		if len(electronics.Children) != 1 || electronics.Children[0].Slug != "phones" || electronics.Children[0].TotalProductCount != 1 {
+			t.Errorf("Expected phones with 1 product under electronics, got %+v", electronics.Children)
+		}
+	})
+
+	t.Run("Filter by category and subcategories", func(t *testing.T) {
+		counts := map[string]int{
+			"/products?category=electronics":                                      2,
+			"/products?category=electronics&include_subcategories=true":           3,
+			"/products?category=phones&include_subcategories=true":                1,
+			"/products?category=electronics&include_subcategories=false":          2,
+			"/products?category=garden&include_subcategories=true":                0,
+			"/products?category=clothing&category=phones&include_subcategories=1": 2,
+		}
+		for url, count := range counts {
+			rr := executeRequest(newRequest("GET", url, "", nil), router)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			var response models.PaginatedResponse
+			if err := parseResponse(rr, &response); err != nil {
+				t.Fatalf("Error unmarshaling response: %v", err)
+			}
+			if response.Total == nil || *response.Total != count {
+				t.Errorf("Expected %d products for %s, got %v", count, url, response.Total)
+			}
+		}
+
+		rr := executeRequest(newRequest("GET", "/products?category=electronics&include_subcategories=maybe", "", nil), router)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Update category", func(t *testing.T) {
+		rr := executeRequest(newRequest("PUT", "/categories/electronics", editorToken, models.CategoryRequest{Name: "Electronics"}), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var updated models.Category
+		if err := parseResponse(rr, &updated); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if updated.Slug != "electronics" || updated.Name != "Electronics" || updated.ProductCount != 2 {
+			t.Errorf("Unexpected updated category: %+v", updated)
+		}
+
+		failures := []struct {
+			url            string
+			body           models.CategoryRequest
+			expectedStatus int
+		}{
+			{url: "/categories/electronics", body: models.CategoryRequest{Name: "Electronics", Parent: "phones"}, expectedStatus: http.StatusBadRequest},
+			{url: "/categories/electronics", body: models.CategoryRequest{Name: "Electronics", Parent: "outdoors"}, expectedStatus: http.StatusBadRequest},
+			{url: "/categories/electronics", body: models.CategoryRequest{Slug: "gadgets", Name: "Gadgets"}, expectedStatus: http.StatusBadRequest},
+			{url: "/categories/electronics", body: models.CategoryRequest{Name: ""}, expectedStatus: http.StatusBadRequest},
+			{url: "/categories/garden", body: models.CategoryRequest{Name: "Garden"}, expectedStatus: http.StatusNotFound},
+		}
+		for _, failure := range failures {
+			rr := executeRequest(newRequest("PUT", failure.url, editorToken, failure.body), router)
+			checkResponseCode(t, failure.expectedStatus, rr.Code)
+		}
+
+		rr = executeRequest(newRequest("PUT", "/categories/electronics", userToken, models.CategoryRequest{Name: "Gadgets"}), router)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Delete category", func(t *testing.T) {
+		for _, slug := range []string{"electronics", "phones"} {
+			rr := executeRequest(newRequest("DELETE", "/categories/"+slug, editorToken, nil), router)
+			checkResponseCode(t, http.StatusConflict, rr.Code)
+		}
+
+		rr := executeRequest(newRequest("POST", "/categories", editorToken, models.CategoryRequest{Slug: "garden", Name: "Garden"}), router)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		rr = executeRequest(newRequest("DELETE", "/categories/garden", userToken, nil), router)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+		rr = executeRequest(newRequest("DELETE", "/categories/garden", editorToken, nil), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		rr = executeRequest(newRequest("DELETE", "/categories/garden", editorToken, nil), router)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -5,37 +5,25 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"testing"
 
-	"github.com/najwa/product-catalog-api/internal/auth"
 	"github.com/najwa/product-catalog-api/internal/db"
-	"github.com/najwa/product-catalog-api/internal/handlers"
 	"github.com/najwa/product-catalog-api/internal/middleware"
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
 func TestFavoritesHandler(t *testing.T) {
+	t.Parallel()
+
 	// Set up test database
-	dbPath := filepath.Join(os.TempDir(), "test_favorites.db")
-	
-	// Clean up the database file after the test
-	defer os.Remove(dbPath)
-	
-	// Initialize the database
-	err := db.Initialize(dbPath)
-	if err != nil {
-		t.Fatalf("Error initializing database: %v", err)
-	}
-	defer db.Close()
+	h, store := newTestHandlers(t)
 	
 	// Seed the database with test data
-	userID := seedTestUser()
-	seedTestProductsForFavorites()
+	userID := seedTestUser(store)
+	seedTestProductsForFavorites(store)
 	
 	// Generate a JWT token for the test user
-	token, err := auth.GenerateToken(userID)
+	token, err := h.Auth.GenerateToken(userID, models.RoleUser, 0)
 	if err != nil {
 		t.Fatalf("Error generating token: %v", err)
 	}
@@ -67,7 +55,7 @@ func TestFavoritesHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 		
 		// Create a handler with auth middleware
-		handler := middleware.AuthMiddleware(http.HandlerFunc(handlers.AddFavoriteHandler))
+		handler := middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.AddFavoriteHandler))
 		
 		// Serve the request
 		handler.ServeHTTP(rr, req)
@@ -93,7 +81,7 @@ func TestFavoritesHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 		
 		// Create a handler with auth middleware
-		handler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetFavoritesHandler))
+		handler := middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.GetFavoritesHandler))
 		
 		// Serve the request
 		handler.ServeHTTP(rr, req)
@@ -104,18 +92,82 @@ func TestFavoritesHandler(t *testing.T) {
 		}
 		
 		// Check the response body
-		var favorites []models.Product
-		err = json.Unmarshal(rr.Body.Bytes(), &favorites)
+		var response struct {
+			Total   int                      `json:"total"`
+			Results []models.FavoriteProduct `json:"results"`
+		}
+		err = json.Unmarshal(rr.Body.Bytes(), &response)
 		if err != nil {
 			t.Fatalf("Error unmarshaling response: %v", err)
 		}
 		
 		// We should have 1 favorite (added in the previous test)
-		if len(favorites) != 1 {
-			t.Errorf("Expected 1 favorite, got %d", len(favorites))
+		if response.Total != 1 || len(response.Results) != 1 {
+			t.Fatalf("Expected 1 favorite, got total %d with %d results", response.Total, len(response.Results))
+		}
+		
+		// The notes and timestamps should be returned with the product
+		favorite := response.Results[0]
+		if favorite.ID != 1 || favorite.Notes != "Test note" {
+			t.Errorf("Expected product 1 with notes %q, got product %d with notes %q", "Test note", favorite.ID, favorite.Notes)
+		}
+		if favorite.CreatedAt.IsZero() || favorite.UpdatedAt.IsZero() {
+			t.Errorf("Expected created_at and updated_at to be set")
 		}
 	})
 	
+	// Test removing a favorite
+	t.Run("Remove favorite", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", "/favorites/1", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		handler := h.Router()
+		rr := executeRequest(req, handler)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		// The favorite should no longer be listed
+		req, _ = http.NewRequest("GET", "/favorites", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr = executeRequest(req, middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.GetFavoritesHandler)))
+
+		var response models.PaginatedResponse
+		if err := parseResponse(rr, &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if response.Total == nil || *response.Total != 0 {
+			t.Errorf("Expected 0 favorites, got %v", response.Total)
+		}
+	})
+
+	// Test removing a favorite that does not exist
+	t.Run("Remove missing favorite", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", "/favorites/1", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		handler := h.Router()
+		rr := executeRequest(req, handler)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	// Test removing a favorite with an invalid product ID
+	t.Run("Remove favorite with invalid ID", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", "/favorites/abc", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		handler := h.Router()
+		rr := executeRequest(req, handler)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
 	// Test unauthorized access
 	t.Run("Unauthorized access", func(t *testing.T) {
 		// Create a request
@@ -130,7 +182,7 @@ func TestFavoritesHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 		
 		// Create a handler with auth middleware
-		handler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetFavoritesHandler))
+		handler := middleware.Authenticate(h.Auth, h.Revocations)(http.HandlerFunc(h.GetFavoritesHandler))
 		
 		// Serve the request
 		handler.ServeHTTP(rr, req)
@@ -143,20 +195,14 @@ func TestFavoritesHandler(t *testing.T) {
 }
 
 // seedTestUser seeds the database with a test user and returns the user ID
-func seedTestUser() int {
-	// Clear the users table
-	db.DB.Exec("DELETE FROM users")
-	
+func seedTestUser(store db.UserStore) int {
 	// Create a test user
-	user, _ := db.CreateUser("testuser", "password")
+	user, _ := store.CreateUser("testuser", "password", models.RoleUser)
 	return user.ID
 }
 
 // seedTestProductsForFavorites seeds the database with test products for favorites
-func seedTestProductsForFavorites() {
-	// Clear the products table
-	db.DB.Exec("DELETE FROM products")
-	
+func seedTestProductsForFavorites(store db.ProductStore) {
 	// Insert test products
 	products := []struct {
 		title    string
@@ -179,9 +225,11 @@ func seedTestProductsForFavorites() {
 	}
 	
 	for _, p := range products {
-		db.DB.Exec(
-			"INSERT INTO products (title, price, category, image) VALUES (?, ?, ?, ?)",
-			p.title, p.price, p.category, p.image,
-		)
+		store.CreateProduct(models.Product{
+			Title:    p.title,
+			Price:    p.price,
+			Category: p.category,
+			Image:    p.image,
+			})
 	}
 }
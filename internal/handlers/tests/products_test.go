@@ -1,34 +1,27 @@
 package tests
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/najwa/product-catalog-api/internal/db"
-	"github.com/najwa/product-catalog-api/internal/handlers"
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
 func TestProductsHandler(t *testing.T) {
+	t.Parallel()
+
 	// Set up test database
-	dbPath := filepath.Join(os.TempDir(), "test_products.db")
-	
-	// Clean up the database file after the test
-	defer os.Remove(dbPath)
-	
-	// Initialize the database
-	err := db.Initialize(dbPath)
-	if err != nil {
-		t.Fatalf("Error initializing database: %v", err)
-	}
-	defer db.Close()
+	h, store := newTestHandlers(t)
 	
 	// Seed the database with test data
-	seedTestProducts()
+	seedTestProducts(store)
 	
 	// Test cases
 	testCases := []struct {
@@ -36,6 +29,7 @@ func TestProductsHandler(t *testing.T) {
 		url            string
 		expectedStatus int
 		expectedCount  int
+		expectedError  string
 	}{
 		{
 			name:           "Get all products",
@@ -56,10 +50,22 @@ func TestProductsHandler(t *testing.T) {
 			expectedCount:  3,
 		},
 		{
-			name:           "Search by title",
-			url:            "/products?search=phone",
+			name:           "Sort by several fields",
+			url:            "/products?sort=-rating,title,-newest",
 			expectedStatus: http.StatusOK,
-			expectedCount:  1, // We'll seed 1 product with "phone" in the title
+			expectedCount:  3,
+		},
+		{
+			name:           "Search by word prefix",
+			url:            "/products?search=smart",
+			expectedStatus: http.StatusOK,
+			expectedCount:  1, // We'll seed 1 product with a word starting with "smart"
+		},
+		{
+			name:           "Search by relevance",
+			url:            "/products?search=electronics&sort=relevance",
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
 		},
 		{
 			name:           "Pagination",
@@ -67,6 +73,126 @@ func TestProductsHandler(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedCount:  2,
 		},
+		{
+			name:           "Several categories",
+			url:            "/products?category=electronics&category=clothing",
+			expectedStatus: http.StatusOK,
+			expectedCount:  3,
+		},
+		{
+			name:           "Price range",
+			url:            "/products?min_price=19.99&max_price=500",
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:           "In stock",
+			url:            "/products?in_stock=true",
+			expectedStatus: http.StatusOK,
+			expectedCount:  1, // Only the smartphone has stock
+		},
+		{
+			name:           "Attributes",
+			url:            "/products?attr.color=black&attr.color=white",
+			expectedStatus: http.StatusOK,
+			expectedCount:  1, // Only the smartphone has a color
+		},
+		{
+			name:           "Invalid page",
+			url:            "/products?page=two",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Page must be a positive integer",
+		},
+		{
+			name:           "Invalid limit",
+			url:            "/products?limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Limit must be a positive integer",
+		},
+		{
+			name:           "Limit above the maximum",
+			url:            "/products?limit=1000000",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Limit must be at most 100",
+		},
+		{
+			name:           "Page out of range",
+			url:            "/products?page=9223372036854775807",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Page is out of range",
+		},
+		{
+			name:           "Invalid minimum price",
+			url:            "/products?min_price=cheap",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "min_price must be a number greater than or equal to 0",
+		},
+		{
+			name:           "Negative maximum price",
+			url:            "/products?max_price=-1",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "max_price must be a number greater than or equal to 0",
+		},
+		{
+			name:           "Inverted price range",
+			url:            "/products?min_price=100&max_price=10",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "min_price must be less than or equal to max_price",
+		},
+		{
+			name:           "Invalid in_stock",
+			url:            "/products?in_stock=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "in_stock must be true or false",
+		},
+		{
+			name:           "Unnamed attribute",
+			url:            "/products?attr.=red",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Attribute filters must name the attribute",
+		},
+		{
+			name:           "Unknown sort field",
+			url:            "/products?sort=-price,color",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  `Invalid sort: sort field "color" is unknown`,
+		},
+		{
+			name:           "Repeated sort field",
+			url:            "/products?sort=price,-price",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid sort: sort field price is listed more than once",
+		},
+		{
+			name:           "Reversed relevance",
+			url:            "/products?search=smart&sort=-relevance",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid sort: sort field relevance cannot be reversed",
+		},
+		{
+			name:           "Invalid cursor",
+			url:            "/products?cursor=not-a-cursor",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid cursor",
+		},
+		{
+			name:           "Cursor and page",
+			url:            "/products?cursor=not-a-cursor&page=2",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Page and cursor cannot be used together",
+		},
+		{
+			name:           "Cursor on a search by relevance",
+			url:            "/products?search=smart&sort=relevance&cursor=not-a-cursor",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Cursors are not supported for searches sorted by relevance",
+		},
+		{
+			name:           "Unknown facet",
+			url:            "/products?facets=category,color",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  `Invalid facets: facet "color" is unknown`,
+		},
 	}
 	
 	for _, tc := range testCases {
@@ -81,7 +207,7 @@ func TestProductsHandler(t *testing.T) {
 			rr := httptest.NewRecorder()
 			
 			// Create a handler
-			handler := http.HandlerFunc(handlers.ProductsHandler)
+			handler := http.HandlerFunc(h.ProductsHandler)
 			
 			// Serve the request
 			handler.ServeHTTP(rr, req)
@@ -91,6 +217,17 @@ func TestProductsHandler(t *testing.T) {
 				t.Errorf("Handler returned wrong status code: got %v want %v", status, tc.expectedStatus)
 			}
 			
+			if tc.expectedError != "" {
+				var response models.ErrorResponse
+				if err := parseResponse(rr, &response); err != nil {
+					t.Fatalf("Error unmarshaling response: %v", err)
+				}
+				if !strings.HasPrefix(response.Error, tc.expectedError) {
+					t.Errorf("Expected error starting with %q, got %q", tc.expectedError, response.Error)
+				}
+				return
+			}
+			
 			// Check the response body
 			var response struct {
 				Total   int             `json:"total"`
@@ -111,23 +248,430 @@ func TestProductsHandler(t *testing.T) {
 	}
 }
 
+func TestProductsPaginationMetadata(t *testing.T) {
+	t.Parallel()
+
+	h, store := newTestHandlers(t)
+	seedTestProducts(store)
+
+	testCases := []struct {
+		name          string
+		url           string
+		expectedPages int
+		expectedNext  bool
+		expectedLinks string
+	}{
+		{
+			name:          "First page",
+			url:           "/products?category=electronics&category=clothing&limit=2",
+			expectedPages: 2,
+			expectedNext:  true,
+			expectedLinks: `</products?category=electronics&category=clothing&limit=2&page=1>; rel="first", ` +
+				`</products?category=electronics&category=clothing&limit=2&page=2>; rel="next", ` +
+				`</products?category=electronics&category=clothing&limit=2&page=2>; rel="last"`,
+		},
+		{
+			name:          "Last page",
+			url:           "/products?limit=2&page=2",
+			expectedPages: 2,
+			expectedLinks: `</products?limit=2&page=1>; rel="first", </products?limit=2&page=1>; rel="prev", ` +
+				`</products?limit=2&page=2>; rel="last"`,
+		},
+		{
+			name:          "Page past the end",
+			url:           "/products?limit=2&page=7",
+			expectedPages: 2,
+			expectedLinks: `</products?limit=2&page=1>; rel="first", </products?limit=2&page=2>; rel="prev", ` +
+				`</products?limit=2&page=2>; rel="last"`,
+		},
+		{
+			name:          "No results",
+			url:           "/products?category=garden",
+			expectedPages: 0,
+			expectedLinks: `</products?category=garden&page=1>; rel="first", </products?category=garden&page=1>; rel="last"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.url, nil)
+			if err != nil {
+				t.Fatalf("Error creating request: %v", err)
+			}
+			rr := executeRequest(req, http.HandlerFunc(h.ProductsHandler))
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			var response struct {
+				TotalPages *int `json:"total_pages"`
+				HasNext    bool `json:"has_next"`
+			}
+			if err := parseResponse(rr, &response); err != nil {
+				t.Fatalf("Error unmarshaling response: %v", err)
+			}
+			if response.TotalPages == nil || *response.TotalPages != tc.expectedPages || response.HasNext != tc.expectedNext {
+				t.Errorf("Expected %d pages and has_next %v, got %v and %v", tc.expectedPages, tc.expectedNext, response.TotalPages, response.HasNext)
+			}
+			if links := rr.Header().Get("Link"); links != tc.expectedLinks {
+				t.Errorf("Expected Link header %s, got %s", tc.expectedLinks, links)
+			}
+		})
+	}
+}
+
+func TestProductsCursorPagination(t *testing.T) {
+	t.Parallel()
+
+	h, store := newTestHandlers(t)
+	seedTestProducts(store)
+
+	type page struct {
+		Total      *int             `json:"total"`
+		Page       int              `json:"page"`
+		Results    []models.Product `json:"results"`
+		NextCursor string           `json:"next_cursor"`
+		HasNext    bool             `json:"has_next"`
+	}
+
+	// list gets a page of products, checking the status code
+	list := func(url string, expectedStatus int) page {
+		t.Helper()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		rr := executeRequest(req, http.HandlerFunc(h.ProductsHandler))
+		checkResponseCode(t, expectedStatus, rr.Code)
+
+		var response page
+		if err := parseResponse(rr, &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		return response
+	}
+
+	// The first page is numbered and counted, and points to the next one
+	first := list("/products?sort=price_asc&limit=2", http.StatusOK)
+	if first.Total == nil || *first.Total != 3 || first.Page != 1 || first.NextCursor == "" || !first.HasNext {
+		t.Fatalf("Expected the first of 3 products with a next cursor, got %+v", first)
+	}
+
+	// Products created meanwhile don't shift the following pages
+	if _, err := store.CreateProduct(models.Product{Title: "Socks", Price: 4.99, Category: "clothing", Image: "https://example.com/socks.jpg"}); err != nil {
+		t.Fatalf("Error creating product: %v", err)
+	}
+
+	second := list("/products?sort=price_asc&limit=2&cursor="+first.NextCursor, http.StatusOK)
+	if second.Total != nil || second.Page != 0 || second.NextCursor != "" || second.HasNext {
+		t.Errorf("Expected the last page without count or next cursor, got %+v", second)
+	}
+	titles := []string{}
+	for _, product := range append(first.Results, second.Results...) {
+		titles = append(titles, product.Title)
+	}
+	if strings.Join(titles, ",") != "T-Shirt,Smartphone,Laptop" {
+		t.Errorf("Expected every product once in price order, got %v", titles)
+	}
+
+	// Cursors only resume the sort they were issued for
+	list("/products?sort=price_desc&limit=2&cursor="+first.NextCursor, http.StatusBadRequest)
+
+	// Cursors can't be tampered with
+	payload, signature, _ := strings.Cut(first.NextCursor, ".")
+	list("/products?sort=price_asc&cursor="+payload+"x."+signature, http.StatusBadRequest)
+
+	// Cursors of other servers are rejected
+	other, _ := newTestHandlers(t)
+	req, _ := http.NewRequest("GET", "/products?sort=price_asc&limit=2&cursor="+first.NextCursor, nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, http.HandlerFunc(other.ProductsHandler)).Code)
+}
+
+func TestProductsFacets(t *testing.T) {
+	t.Parallel()
+
+	h, store := newTestHandlers(t)
+	seedTestProducts(store)
+
+	// get returns the facets of a listing, nil if it has none
+	get := func(url string) *models.Facets {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		rr := executeRequest(req, http.HandlerFunc(h.ProductsHandler))
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Facets *models.Facets `json:"facets"`
+		}
+		if err := parseResponse(rr, &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		return response.Facets
+	}
+
+	if facets := get("/products"); facets != nil {
+		t.Errorf("Expected no facets unless requested, got %+v", facets)
+	}
+
+	// Each facet ignores its own filter: the category facet counts the
+	// clothing under the price limit, the price facet the laptop
+	facets := get("/products?category=electronics&max_price=600&facets=category,price")
+	if facets == nil {
+		t.Fatal("Expected facets")
+	}
+	expectedCategories := []models.CategoryCount{{Category: "clothing", Count: 1}, {Category: "electronics", Count: 1}}
+	if !reflect.DeepEqual(facets.Category, expectedCategories) {
+		t.Errorf("Expected category counts %+v, got %+v", expectedCategories, facets.Category)
+	}
+	counts := map[float64]int{}
+	for _, priceRange := range facets.Price {
+		counts[priceRange.Min] = priceRange.Count
+	}
+	if len(facets.Price) != 8 || facets.Price[7].Max != nil || counts[250] != 1 || counts[500] != 1 || counts[10] != 0 {
+		t.Errorf("Expected the smartphone and laptop in their price ranges, got %+v", facets.Price)
+	}
+
+	// Facets follow the search
+	facets = get("/products?search=shirt&facets=price")
+	if facets == nil || facets.Category != nil || len(facets.Price) != 8 || facets.Price[1].Count != 1 {
+		t.Errorf("Expected the t-shirt alone in the 10-25 range, got %+v", facets)
+	}
+
+	// Pages read by cursor count every matching product too
+	req, err := http.NewRequest("GET", "/products?limit=1", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	var first models.PaginatedResponse
+	if err := parseResponse(executeRequest(req, http.HandlerFunc(h.ProductsHandler)), &first); err != nil || first.NextCursor == "" {
+		t.Fatalf("Expected a next cursor, got %+v, %v", first, err)
+	}
+	facets = get("/products?limit=1&facets=category&cursor=" + first.NextCursor)
+	expectedCategories = []models.CategoryCount{{Category: "electronics", Count: 2}, {Category: "clothing", Count: 1}}
+	if facets == nil || !reflect.DeepEqual(facets.Category, expectedCategories) {
+		t.Errorf("Expected category counts %+v, got %+v", expectedCategories, facets)
+	}
+}
+
+func TestProductHandler(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	h, store := newTestHandlers(t)
+
+	seedTestProducts(store)
+
+	// Look up the ID of a seeded product
+	products, err := store.GetProducts(db.ProductQuery{Search: "Laptop"})
+	if err != nil || len(products) != 1 {
+		t.Fatalf("Error looking up product: %v", err)
+	}
+	id := products[0].ID
+
+	testCases := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{
+			name:           "Existing product",
+			url:            "/products/" + strconv.Itoa(id),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing product",
+			url:            "/products/999999",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid product ID",
+			url:            "/products/abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.url, nil)
+			if err != nil {
+				t.Fatalf("Error creating request: %v", err)
+			}
+
+			rr := executeRequest(req, h.Router())
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+
+			if tc.expectedStatus == http.StatusOK {
+				var product models.Product
+				if err := parseResponse(rr, &product); err != nil {
+					t.Fatalf("Error unmarshaling response: %v", err)
+				}
+				if product.ID != id || product.Title != "Laptop" {
+					t.Errorf("Expected product %d (Laptop), got %d (%s)", id, product.ID, product.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestProductAdminHandlers(t *testing.T) {
+	t.Parallel()
+
+	// Set up test database
+	h, store := newTestHandlers(t)
+
+	seedTestProducts(store)
+
+	// Create an admin, a catalog editor and a regular user and generate their tokens
+	tokens := map[string]string{}
+	for _, role := range []string{models.RoleAdmin, models.RoleEditor, models.RoleUser} {
+		user, err := store.CreateUser("test"+role, "password", role)
+		if err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+		tokens[role], err = h.Auth.GenerateToken(user.ID, user.Role, user.TokenVersion)
+		if err != nil {
+			t.Fatalf("Error generating token: %v", err)
+		}
+	}
+	adminToken, editorToken, userToken := tokens[models.RoleAdmin], tokens[models.RoleEditor], tokens[models.RoleUser]
+
+	router := h.Router()
+
+	// newRequest builds a request with a JSON body and an optional bearer token
+	newRequest := func(method, url, token string, body interface{}) *http.Request {
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatalf("Error marshaling request body: %v", err)
+			}
+		}
+		req, err := http.NewRequest(method, url, &buf)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req
+	}
+
+	valid := models.ProductRequest{
+		Title:      "Tablet",
+		Price:      299.99,
+		Category:   "electronics",
+		Image:      "https://example.com/tablet.jpg",
+		Stock:      4,
+		Attributes: map[string]string{"color": "white"},
+	}
+
+	var created models.Product
+
+	t.Run("Create product", func(t *testing.T) {
+		rr := executeRequest(newRequest("POST", "/products", adminToken, valid), router)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		if err := parseResponse(rr, &created); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if created.ID == 0 || created.Title != valid.Title || created.Stock != 4 || created.Attributes["color"] != "white" {
+			t.Errorf("Unexpected created product: %+v", created)
+		}
+	})
+
+	t.Run("Create product requires editor role", func(t *testing.T) {
+		rr := executeRequest(newRequest("POST", "/products", editorToken, valid), router)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		rr = executeRequest(newRequest("POST", "/products", userToken, valid), router)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+		rr = executeRequest(newRequest("POST", "/products", "", valid), router)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Create product validation", func(t *testing.T) {
+		invalid := []models.ProductRequest{
+			{Title: " ", Price: 1, Category: "electronics", Image: "https://example.com/a.jpg"},
+			{Title: "Tablet", Price: -1, Category: "electronics", Image: "https://example.com/a.jpg"},
+			{Title: "Tablet", Price: 1, Category: "", Image: "https://example.com/a.jpg"},
+			{Title: "Tablet", Price: 1, Category: "electronics", Image: "not a url"},
+			{Title: "Tablet", Price: 1, Category: "electronics", Image: "ftp://example.com/a.jpg"},
+			{Title: "Tablet", Price: 1, Category: "electronics", Image: "https://example.com/a.jpg", Stock: -1},
+			{Title: "Tablet", Price: 1, Category: "electronics", Image: "https://example.com/a.jpg", Rating: 5.5},
+			{Title: "Tablet", Price: 1, Category: "electronics", Image: "https://example.com/a.jpg", Attributes: map[string]string{" ": "x"}},
+		}
+		for _, body := range invalid {
+			rr := executeRequest(newRequest("POST", "/products", adminToken, body), router)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Replace product", func(t *testing.T) {
+		body := valid
+		body.Title = "Tablet Pro"
+		url := "/products/" + strconv.Itoa(created.ID)
+		rr := executeRequest(newRequest("PUT", url, adminToken, body), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		product, err := store.GetProductByID(created.ID)
+		if err != nil {
+			t.Fatalf("Error getting product: %v", err)
+		}
+		if product.Title != "Tablet Pro" {
+			t.Errorf("Expected title %q, got %q", "Tablet Pro", product.Title)
+		}
+
+		rr = executeRequest(newRequest("PUT", "/products/999999", adminToken, body), router)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Patch product", func(t *testing.T) {
+		price, rating := 249.99, 4.5
+		url := "/products/" + strconv.Itoa(created.ID)
+		rr := executeRequest(newRequest("PATCH", url, adminToken, models.ProductPatchRequest{Price: &price, Rating: &rating}), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		product, err := store.GetProductByID(created.ID)
+		if err != nil {
+			t.Fatalf("Error getting product: %v", err)
+		}
+		if product.Price != price || product.Rating != rating || product.Title != "Tablet Pro" {
+			t.Errorf("Expected only the price and rating to change, got %+v", product)
+		}
+
+		negative := -5.0
+		rr = executeRequest(newRequest("PATCH", url, adminToken, models.ProductPatchRequest{Price: &negative}), router)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Delete product", func(t *testing.T) {
+		url := "/products/" + strconv.Itoa(created.ID)
+		rr := executeRequest(newRequest("DELETE", url, adminToken, nil), router)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		rr = executeRequest(newRequest("DELETE", url, adminToken, nil), router)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
+
 // seedTestProducts seeds the database with test products
-func seedTestProducts() {
-	// Clear the products table
-	db.DB.Exec("DELETE FROM products")
-	
+func seedTestProducts(store db.ProductStore) {
 	// Insert test products
 	products := []struct {
-		title    string
-		price    float64
-		category string
-		image    string
+		title      string
+		price      float64
+		category   string
+		image      string
+		stock      int
+		attributes map[string]string
 	}{
 		{
-			title:    "Smartphone",
-			price:    499.99,
-			category: "electronics",
-			image:    "https://example.com/smartphone.jpg",
+			title:      "Smartphone",
+			price:      499.99,
+			category:   "electronics",
+			image:      "https://example.com/smartphone.jpg",
+			stock:      5,
+			attributes: map[string]string{"color": "black"},
 		},
 		{
 			title:    "Laptop",
@@ -144,9 +688,13 @@ func seedTestProducts() {
 	}
 	
 	for _, p := range products {
-		db.DB.Exec(
-			"INSERT INTO products (title, price, category, image) VALUES (?, ?, ?, ?)",
-			p.title, p.price, p.category, p.image,
-		)
+		store.CreateProduct(models.Product{
+			Title:      p.title,
+			Price:      p.price,
+			Category:   p.category,
+			Image:      p.image,
+			Stock:      p.stock,
+			Attributes: p.attributes,
+			})
 	}
 }
@@ -4,9 +4,50 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/handlers"
+	"github.com/najwa/product-catalog-api/internal/revocation"
 )
 
+// newTestHandlers returns handlers backed by a fresh database of their own,
+// along with its store. Everything is closed when the test ends.
+func newTestHandlers(t *testing.T) (*handlers.Handlers, *db.SQLiteStore) {
+	return newTestHandlersAt(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+// newTestHandlersAt is like newTestHandlers with the database at dbPath
+func newTestHandlersAt(t *testing.T, dbPath string) (*handlers.Handlers, *db.SQLiteStore) {
+	t.Helper()
+
+	store, err := db.OpenSQLite(dbPath, db.Options{})
+	if err != nil {
+		t.Fatalf("Error initializing database: %v", err)
+	}
+
+	issuer, err := auth.NewIssuer(auth.Config{})
+	if err != nil {
+		store.Close()
+		t.Fatalf("Error initializing authentication: %v", err)
+	}
+
+	revocations, err := revocation.New(store, store, issuer.TokenExpiry())
+	if err != nil {
+		store.Close()
+		t.Fatalf("Error initializing revocation: %v", err)
+	}
+
+	h := handlers.New(store, issuer, revocations, handlers.Config{})
+	t.Cleanup(func() {
+		h.Revocations.Close()
+		store.Close()
+	})
+	return h, store
+}
+
 // executeRequest creates a new ResponseRecorder, executes the request against the handler,
 // and returns the response recorder
 func executeRequest(req *http.Request, handler http.Handler) *httptest.ResponseRecorder {
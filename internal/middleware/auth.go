@@ -9,32 +9,87 @@ import (
 	"github.com/najwa/product-catalog-api/internal/models"
 )
 
-// UserIDKey is the key used to store the user ID in the request context
-type userIDKey string
+// Each request context key has a type of its own, so that no two keys can
+// collide
+type (
+	userIDKey string
+	roleKey   string
+	claimsKey string
+)
 
+// UserIDKey is the key used to store the user ID in the request context
 const UserIDKey userIDKey = "userID"
 
-// AuthMiddleware is a middleware that validates JWT tokens
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract the token from the request
-		tokenString, err := auth.ExtractTokenFromRequest(r)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		// Validate the token
-		claims, err := auth.ValidateToken(tokenString)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Add the user ID to the request context
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// RoleKey is the key used to store the user's role in the request context
+const RoleKey roleKey = "role"
+
+// ClaimsKey is the key used to store the validated token claims in the request context
+const ClaimsKey claimsKey = "claims"
+
+// TokenValidator validates access tokens and returns their claims
+type TokenValidator interface {
+	ValidateToken(tokenString string) (*auth.Claims, error)
+}
+
+// RevocationChecker reports whether a validated access token has been revoked
+type RevocationChecker interface {
+	IsRevoked(claims *auth.Claims) (bool, error)
+}
+
+// Authenticate returns a middleware that validates JWT tokens with tokens,
+// rejecting tokens reported as revoked by revocations
+func Authenticate(tokens TokenValidator, revocations RevocationChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Extract the token from the request
+			tokenString, err := auth.ExtractTokenFromRequest(r)
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			// Validate the token
+			claims, err := tokens.ValidateToken(tokenString)
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+
+			// Reject tokens revoked on logout
+			revoked, err := revocations.IsRevoked(claims)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error validating token")
+				return
+			}
+			if revoked {
+				respondWithError(w, http.StatusUnauthorized, "Token has been revoked")
+				return
+			}
+
+			// Add the user ID, role and claims to the request context
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, RoleKey, claims.Role)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole returns a middleware that only lets through users holding one of
+// the given roles, responding with 403 otherwise. It must be wrapped by Authenticate.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := GetUserRole(r)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			respondWithError(w, http.StatusForbidden, "Insufficient privileges")
+		})
+	}
 }
 
 // GetUserID retrieves the user ID from the request context
@@ -43,6 +98,18 @@ func GetUserID(r *http.Request) (int, bool) {
 	return userID, ok
 }
 
+// GetUserRole retrieves the user's role from the request context
+func GetUserRole(r *http.Request) (string, bool) {
+	role, ok := r.Context().Value(RoleKey).(string)
+	return role, ok
+}
+
+// GetClaims retrieves the validated token claims from the request context
+func GetClaims(r *http.Request) (*auth.Claims, bool) {
+	claims, ok := r.Context().Value(ClaimsKey).(*auth.Claims)
+	return claims, ok
+}
+
 // respondWithError responds with an error message
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, models.ErrorResponse{Error: message})
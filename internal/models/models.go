@@ -1,27 +1,91 @@
 package models
 
+import "time"
+
+// User roles
+const (
+	RoleUser   = "user"   // Shoppers: browse products and manage their own favorites
+	RoleEditor = "editor" // Catalog editors: may also create, update and delete products and categories
+	RoleAdmin  = "admin"  // Administrators: full access
+)
+
+// ValidRole reports whether role is one of the known user roles
+func ValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 // User represents a user in the system
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"-"` // Password is not included in JSON responses
+	Role     string `json:"role"`
+
+	// TokenVersion is incremented to invalidate all of the user's access tokens
+	TokenVersion int `json:"-"`
 }
 
 // Product represents a product in the catalog
 type Product struct {
-	ID       int     `json:"id"`
-	Title    string  `json:"title"`
-	Price    float64 `json:"price"`
-	Category string  `json:"category"`
-	Image    string  `json:"image"`
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Category    string  `json:"category"`
+	Image       string  `json:"image"`
+	Stock       int     `json:"stock"`      // Units available
+	Rating      float64 `json:"rating"`     // Average customer rating, from 0 to 5
+	Popularity  int     `json:"popularity"` // Number of users who favorited the product
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// Attributes are free-form properties of the product, such as its color
+	Attributes map[string]string `json:"attributes"`
+
+	// Snippet is an excerpt of the product with the searched words in <mark>
+	// elements, HTML-escaped otherwise. It is only set in search results.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Category is a category of the catalog. Categories form a tree, and
+// products refer to theirs by slug.
+type Category struct {
+	ID     int    `json:"id"`
+	Slug   string `json:"slug"`
+	Name   string `json:"name"`             // Display name
+	Parent string `json:"parent,omitempty"` // Slug of the parent category, empty for top-level categories
+
+	// ProductCount is the number of products in the category itself
+	ProductCount int `json:"product_count"`
+}
+
+// CategoryNode represents a category in the category tree
+type CategoryNode struct {
+	Category
+	TotalProductCount int            `json:"total_product_count"` // Products in the category and its descendants
+	Children          []CategoryNode `json:"children"`
 }
 
 // Favorite represents a user's favorite product
 type Favorite struct {
-	ID        int    `json:"id"`
-	UserID    int    `json:"user_id"`
-	ProductID int    `json:"product_id"`
-	Notes     string `json:"notes,omitempty"` // Optional notes (bonus feature)
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	ProductID int       `json:"product_id"`
+	Notes     string    `json:"notes,omitempty"` // Optional notes (bonus feature)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FavoriteProduct represents a favorited product together with the user's notes
+type FavoriteProduct struct {
+	Product
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // LoginRequest represents the login request body
@@ -30,9 +94,29 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RegisterRequest represents the registration request body
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 // LoginResponse represents the login response body
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`         // Short-lived access token
+	TokenType    string `json:"token_type"`    // Always "Bearer"
+	ExpiresIn    int    `json:"expires_in"`    // Access token lifetime in seconds
+	RefreshToken string `json:"refresh_token"` // Single-use token for POST /token/refresh
+}
+
+// LogoutRequest represents the logout request body
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"` // Refresh token of the session, revoked along with the access token
+	AllSessions  bool   `json:"all_sessions,omitempty"`  // Log out of every session of the user
+}
+
+// RefreshRequest represents the token refresh request body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // FavoriteRequest represents the request to add a favorite
@@ -43,10 +127,72 @@ type FavoriteRequest struct {
 
 // PaginatedResponse represents a paginated response
 type PaginatedResponse struct {
-	Total   int         `json:"total"`
-	Page    int         `json:"page"`
-	Limit   int         `json:"limit"`
-	Results interface{} `json:"results"`
+	Total      *int        `json:"total,omitempty"`       // Omitted for pages requested by cursor, which aren't counted
+	TotalPages *int        `json:"total_pages,omitempty"` // Omitted for pages requested by cursor
+	Page       int         `json:"page,omitempty"`        // Omitted for pages requested by cursor
+	Limit      int         `json:"limit"`
+	HasNext    bool        `json:"has_next"`
+	Results    interface{} `json:"results"`
+
+	// NextCursor resumes the listing after the results, if more follow
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// Facets counts the matching products per category and price range, if
+	// requested
+	Facets *Facets `json:"facets,omitempty"`
+}
+
+// Facets counts the products of a listing per value of each requested facet.
+// Each facet ignores the listing's own filter on it, so that its other values
+// keep their counts.
+type Facets struct {
+	Category []CategoryCount `json:"category,omitempty"` // Categories with matching products, most products first
+	Price    []PriceRange    `json:"price,omitempty"`    // Every price range, cheapest first
+}
+
+// CategoryCount is the number of products in a category
+type CategoryCount struct {
+	Category string `json:"category"` // Slug of the category
+	Count    int    `json:"count"`
+}
+
+// PriceRange is the number of products priced from Min, inclusive, up to Max,
+// exclusive
+type PriceRange struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"` // Omitted for the highest range, which has no upper bound
+	Count int      `json:"count"`
+}
+
+// ProductRequest represents the request to create or replace a product
+type ProductRequest struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"` // Optional
+	Price       float64           `json:"price"`
+	Category    string            `json:"category"`
+	Image       string            `json:"image"`
+	Stock       int               `json:"stock,omitempty"`      // Optional, 0 by default
+	Rating      float64           `json:"rating,omitempty"`     // Optional, 0 by default
+	Attributes  map[string]string `json:"attributes,omitempty"` // Optional
+}
+
+// ProductPatchRequest represents a partial product update; omitted fields are left unchanged
+type ProductPatchRequest struct {
+	Title       *string           `json:"title,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Price       *float64          `json:"price,omitempty"`
+	Category    *string           `json:"category,omitempty"`
+	Image       *string           `json:"image,omitempty"`
+	Stock       *int              `json:"stock,omitempty"`
+	Rating      *float64          `json:"rating,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"` // Replaces all attributes when present
+}
+
+// CategoryRequest represents the request to create or update a category
+type CategoryRequest struct {
+	Slug   string `json:"slug"`             // Required on creation; slugs cannot be changed
+	Name   string `json:"name"`             // Display name
+	Parent string `json:"parent,omitempty"` // Optional, slug of the parent category
 }
 
 // ErrorResponse represents an error response
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptID is the identifier under which bcrypt is registered. Encoded bcrypt
+// hashes carry their version instead ($2a$, $2b$ or $2y$).
+const bcryptID = "bcrypt"
+
+// DefaultBcryptCost is the default bcrypt work factor
+const DefaultBcryptCost = 12
+
+// Bcrypt hashes passwords with bcrypt. Encoded hashes use the standard
+// modular crypt format: $2a$12$<salt and hash>
+type Bcrypt struct {
+	cost int
+}
+
+// NewBcrypt creates a bcrypt hasher with the given cost
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
+// ID returns the identifier of the algorithm
+func (h *Bcrypt) ID() string {
+	return bcryptID
+}
+
+// Hash returns the encoded bcrypt hash of a password
+func (h *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Verify reports whether the password matches the encoded bcrypt hash
+func (h *Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	return true, nil
+}
+
+// NeedsRehash reports whether the encoded hash uses a different cost
+func (h *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
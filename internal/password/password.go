@@ -0,0 +1,127 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Hasher hashes and verifies passwords using a single algorithm.
+//
+// Encoded hashes are self-describing: they start with "$<id>$" followed by the
+// algorithm parameters, salt and digest, so a hash can always be verified with
+// the algorithm and parameters that produced it.
+type Hasher interface {
+	// ID returns the identifier of the algorithm in encoded hashes
+	ID() string
+
+	// Hash returns the encoded hash of a password using a random salt
+	Hash(password string) (string, error)
+
+	// Verify reports whether the password matches the encoded hash
+	Verify(password, encoded string) (bool, error)
+
+	// NeedsRehash reports whether the encoded hash was produced with
+	// parameters that differ from the hasher's current ones
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	// ErrUnknownAlgorithm is returned when an encoded hash was produced by an unsupported algorithm
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+	// ErrMalformedHash is returned when an encoded hash cannot be parsed
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// hashers holds every supported algorithm by its encoded hash ID. It is never
+// modified, so it is safe for concurrent use.
+var hashers = map[string]Hasher{
+	"argon2id": NewArgon2id(DefaultArgon2idParams),
+	bcryptID:   NewBcrypt(DefaultBcryptCost),
+	"scrypt":   NewScrypt(DefaultScryptParams),
+}
+
+// New returns a hasher for the algorithm with the given ID, using its default parameters
+func New(id string) (Hasher, error) {
+	switch id {
+	case "argon2id":
+		return NewArgon2id(DefaultArgon2idParams), nil
+	case bcryptID:
+		return NewBcrypt(DefaultBcryptCost), nil
+	case "scrypt":
+		return NewScrypt(DefaultScryptParams), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, id)
+}
+
+// Default returns the hasher used for new passwords unless another one is
+// configured
+func Default() Hasher {
+	return NewArgon2id(DefaultArgon2idParams)
+}
+
+// Verify checks a password against an encoded hash produced by any supported
+// algorithm, including legacy unsalted SHA-256 hashes. When the password
+// matches, needsRehash reports whether the hash should be replaced with one
+// produced by current, the hasher used for new passwords.
+func Verify(current Hasher, password, encoded string) (ok, needsRehash bool, err error) {
+	if isLegacySHA256(encoded) {
+		sum := sha256.Sum256([]byte(password))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(encoded)) == 1
+		return ok, ok, nil
+	}
+
+	id, err := hashID(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	// Hashes of the current algorithm are verified with its own parameters
+	h, found := hashers[id]
+	if id == current.ID() {
+		h, found = current, true
+	}
+	if !found {
+		return false, false, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, id)
+	}
+
+	ok, err = h.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	needsRehash = h.ID() != current.ID() || current.NeedsRehash(encoded)
+	return true, needsRehash, nil
+}
+
+// hashID extracts the algorithm identifier from an encoded hash
+func hashID(encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, "$") {
+		return "", ErrMalformedHash
+	}
+	id, _, found := strings.Cut(encoded[1:], "$")
+	if !found || id == "" {
+		return "", ErrMalformedHash
+	}
+
+	// bcrypt uses several version prefixes for the same algorithm
+	switch id {
+	case "2a", "2b", "2y":
+		return bcryptID, nil
+	}
+	return id, nil
+}
+
+// isLegacySHA256 reports whether encoded is a hex-encoded unsalted SHA-256
+// digest, the format used before hashes became self-describing
+func isLegacySHA256(encoded string) bool {
+	if len(encoded) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
@@ -0,0 +1,117 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptParams are the cost parameters of the scrypt algorithm
+type ScryptParams struct {
+	LogN       uint8 // CPU/memory cost as a power of two
+	R          int
+	P          int
+	SaltLength int
+	KeyLength  int
+}
+
+// DefaultScryptParams follow the OWASP password storage recommendations
+var DefaultScryptParams = ScryptParams{
+	LogN:       17,
+	R:          8,
+	P:          1,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+// Scrypt hashes passwords with scrypt. Encoded hashes use the PHC string
+// format: $scrypt$ln=17,r=8,p=1$<salt>$<hash>
+type Scrypt struct {
+	params ScryptParams
+}
+
+// NewScrypt creates a scrypt hasher with the given parameters
+func NewScrypt(params ScryptParams) *Scrypt {
+	return &Scrypt{params: params}
+}
+
+// ID returns the identifier of the algorithm in encoded hashes
+func (h *Scrypt) ID() string {
+	return "scrypt"
+}
+
+// Hash returns the encoded scrypt hash of a password
+func (h *Scrypt) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error generating salt: %w", err)
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, 1<<h.params.LogN, h.params.R, h.params.P, h.params.KeyLength)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+		h.params.LogN, h.params.R, h.params.P,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches the encoded scrypt hash
+func (h *Scrypt) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeScrypt(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other, err := scrypt.Key([]byte(password), salt, 1<<params.LogN, params.R, params.P, params.KeyLength)
+	if err != nil {
+		return false, fmt.Errorf("error hashing password: %w", err)
+	}
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash reports whether the encoded hash uses different parameters
+func (h *Scrypt) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeScrypt(encoded)
+	if err != nil {
+		return true
+	}
+	return params != h.params
+}
+
+// decodeScrypt parses an encoded scrypt hash
+func decodeScrypt(encoded string) (ScryptParams, []byte, []byte, error) {
+	var params ScryptParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.LogN, &params.R, &params.P); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if params.LogN == 0 || params.LogN > 30 || params.R <= 0 || params.P <= 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	params.SaltLength = len(salt)
+	params.KeyLength = len(key)
+	return params, salt, key, nil
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/password"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	t.Parallel()
+
+	hashers := []password.Hasher{
+		password.NewArgon2id(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}),
+		password.NewBcrypt(4),
+		password.NewScrypt(password.ScryptParams{LogN: 10, R: 8, P: 1, SaltLength: 16, KeyLength: 32}),
+	}
+
+	// New passwords are hashed with the first hasher
+	current := hashers[0]
+
+	for _, h := range hashers {
+		t.Run(h.ID(), func(t *testing.T) {
+			encoded, err := h.Hash("secret123")
+			if err != nil {
+				t.Fatalf("Error hashing password: %v", err)
+			}
+
+			// Every algorithm is recognized from the encoded hash alone
+			ok, needsRehash, err := password.Verify(current, "secret123", encoded)
+			if err != nil || !ok {
+				t.Fatalf("Expected password to verify, got ok=%v err=%v", ok, err)
+			}
+			if needsRehash != (h != current) {
+				t.Errorf("Unexpected needsRehash=%v", needsRehash)
+			}
+
+			ok, _, err = password.Verify(current, "wrong", encoded)
+			if err != nil || ok {
+				t.Errorf("Expected wrong password to be rejected, got ok=%v err=%v", ok, err)
+			}
+
+			// Hashes are salted
+			other, _ := h.Hash("secret123")
+			if other == encoded {
+				t.Errorf("Expected different hashes for the same password")
+			}
+
+			if h.NeedsRehash(encoded) {
+				t.Errorf("Hash should not need a rehash with the parameters that produced it")
+			}
+		})
+	}
+
+	t.Run("Malformed hash", func(t *testing.T) {
+		if _, _, err := password.Verify(current, "secret123", "$unknown$abc"); err == nil {
+			t.Errorf("Expected an error for an unknown algorithm")
+		}
+		if _, _, err := password.Verify(current, "secret123", "not a hash"); err == nil {
+			t.Errorf("Expected an error for a malformed hash")
+		}
+
+		// Parameters the algorithms can't run with are rejected rather than
+		// making them panic
+		for _, encoded := range []string{
+			"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5",
+			"$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5",
+			"$argon2id$v=19$m=1024,t=1,p=1$$a2V5a2V5a2V5a2V5",
+			"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+			"$scrypt$ln=10,r=8,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5",
+			"$scrypt$ln=10,r=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5",
+		} {
+			if _, _, err := password.Verify(current, "secret123", encoded); err == nil {
+				t.Errorf("Expected an error for %s", encoded)
+			}
+		}
+	})
+}
+
+func TestLegacySHA256(t *testing.T) {
+	t.Parallel()
+
+	sum := sha256.Sum256([]byte("secret123"))
+	legacy := hex.EncodeToString(sum[:])
+
+	// Legacy hashes verify, and always need a rehash
+	ok, needsRehash, err := password.Verify(password.Default(), "secret123", legacy)
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("Expected legacy hash to verify and need a rehash, got ok=%v needsRehash=%v err=%v", ok, needsRehash, err)
+	}
+
+	ok, needsRehash, err = password.Verify(password.Default(), "wrong", legacy)
+	if err != nil || ok || needsRehash {
+		t.Errorf("Expected wrong password to be rejected, got ok=%v needsRehash=%v err=%v", ok, needsRehash, err)
+	}
+}
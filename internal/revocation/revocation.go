@@ -0,0 +1,207 @@
+package revocation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/najwa/product-catalog-api/internal/auth"
+	"github.com/najwa/product-catalog-api/internal/db"
+)
+
+const (
+	// refreshInterval is how often the cache is reloaded from the store, so
+	// that revocations made by other instances sharing the database are seen
+	refreshInterval = 30 * time.Second
+
+	// purgeInterval is how often expired revocations are purged
+	purgeInterval = 10 * time.Minute
+)
+
+// Revoker revokes access tokens and checks tokens against the revocations,
+// caching them in memory until the next refresh
+type Revoker struct {
+	users  db.UserStore
+	tokens db.TokenStore
+
+	// tokenExpiry is how long access tokens without an expiry are revoked for
+	tokenExpiry time.Duration
+
+	mu sync.RWMutex
+
+	// revoked caches the IDs of revoked, unexpired access tokens with their expiry
+	revoked map[string]time.Time
+
+	// versions caches the current token version of users seen since the
+	// last refresh
+	versions map[int]int
+
+	// stop stops the worker started by New
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New loads the revoked tokens from the store into the cache and starts
+// refreshing it and purging expired entries periodically. Close must be
+// called before closing the stores. Access tokens without an expiry are
+// revoked for tokenExpiry.
+func New(users db.UserStore, tokens db.TokenStore, tokenExpiry time.Duration) (*Revoker, error) {
+	revoked, err := tokens.GetRevokedTokens()
+	if err != nil {
+		return nil, fmt.Errorf("error loading revoked tokens: %w", err)
+	}
+
+	r := &Revoker{
+		users:       users,
+		tokens:      tokens,
+		tokenExpiry: tokenExpiry,
+		revoked:     revoked,
+		versions:    map[int]int{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go r.loop()
+
+	return r, nil
+}
+
+// Close stops the refresh and purge worker
+func (r *Revoker) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// IsRevoked reports whether an access token has been revoked, either
+// individually or by invalidating all of its user's tokens
+func (r *Revoker) IsRevoked(claims *auth.Claims) (bool, error) {
+	r.mu.RLock()
+	_, isRevoked := r.revoked[claims.ID]
+	version, cached := r.versions[claims.UserID]
+	r.mu.RUnlock()
+
+	if isRevoked {
+		return true, nil
+	}
+
+	if !cached {
+		var err error
+		version, err = r.users.GetTokenVersion(claims.UserID)
+		if errors.Is(err, db.ErrUserNotFound) {
+			// Tokens of deleted users are no longer valid
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		r.setVersion(claims.UserID, version)
+	}
+
+	return claims.TokenVersion < version, nil
+}
+
+// Revoke revokes a single access token until it expires
+func (r *Revoker) Revoke(claims *auth.Claims) error {
+	expiresAt := time.Now().Add(r.tokenExpiry)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := r.tokens.RevokeToken(claims.ID, claims.UserID, expiresAt); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.revoked[claims.ID] = expiresAt
+	r.mu.Unlock()
+
+	return nil
+}
+
+// RevokeAllForUser revokes every access token issued to a user so far
+func (r *Revoker) RevokeAllForUser(userID int) error {
+	version, err := r.users.IncrementTokenVersion(userID)
+	if err != nil {
+		return err
+	}
+
+	r.setVersion(userID, version)
+	return nil
+}
+
+// setVersion caches the token version of a user. Versions only increase, so
+// a version read before a concurrent increment doesn't replace a newer one.
+func (r *Revoker) setVersion(userID, version int) {
+	r.mu.Lock()
+	if cached, ok := r.versions[userID]; !ok || version > cached {
+		r.versions[userID] = version
+	}
+	r.mu.Unlock()
+}
+
+// Refresh reloads the revoked tokens from the store and drops the cached
+// token versions, picking up the logouts of other instances
+func (r *Revoker) Refresh() error {
+	revoked, err := r.tokens.GetRevokedTokens()
+	if err != nil {
+		return fmt.Errorf("error loading revoked tokens: %w", err)
+	}
+
+	r.mu.Lock()
+	// Revocations are never lifted, so tokens revoked here in the meantime
+	// are kept until they expire
+	for jti, expiresAt := range revoked {
+		r.revoked[jti] = expiresAt
+	}
+	r.versions = map[int]int{}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Purge removes expired revocations and refresh tokens from the cache and the
+// store. Expired tokens are rejected anyway.
+func (r *Revoker) Purge() {
+	now := time.Now()
+
+	r.mu.Lock()
+	for jti, expiresAt := range r.revoked {
+		if !expiresAt.After(now) {
+			delete(r.revoked, jti)
+		}
+	}
+	r.mu.Unlock()
+
+	if _, err := r.tokens.DeleteExpiredRevokedTokens(); err != nil {
+		log.Printf("Error purging revoked tokens: %v", err)
+	}
+	if _, err := r.tokens.DeleteExpiredRefreshTokens(); err != nil {
+		log.Printf("Error purging refresh tokens: %v", err)
+	}
+}
+
+// loop calls Refresh every refreshInterval and Purge every purgeInterval
+// until the revoker is closed
+func (r *Revoker) loop() {
+	defer close(r.done)
+
+	refreshTicker := time.NewTicker(refreshInterval)
+	defer refreshTicker.Stop()
+	purgeTicker := time.NewTicker(purgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-refreshTicker.C:
+			if err := r.Refresh(); err != nil {
+				log.Printf("Error refreshing revoked tokens: %v", err)
+			}
+		case <-purgeTicker.C:
+			r.Purge()
+		case <-r.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// Middleware wraps a handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// Router dispatches requests by path and method.
+//
+// Paths use http.ServeMux patterns, so path parameters such as
+// /products/{id} are available through Request.PathValue. Requests for a
+// known path with an unsupported method get a 405 response listing the
+// allowed methods in the Allow header; unknown paths get a 404 response.
+type Router struct {
+	mux        *http.ServeMux
+	routes     map[string]*route
+	middleware []Middleware
+}
+
+// route holds the handlers of a single path by method
+type route struct {
+	handlers map[string]http.Handler
+}
+
+// New creates an empty router
+func New() *Router {
+	r := &Router{
+		mux:    http.NewServeMux(),
+		routes: map[string]*route{},
+	}
+	r.mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		respondWithError(w, http.StatusNotFound, "Not found")
+	})
+	return r
+}
+
+// With returns a router registering its routes on r with the given middleware
+// appended to r's middleware. The first middleware is the outermost.
+func (r *Router) With(middleware ...Middleware) *Router {
+	return &Router{
+		mux:        r.mux,
+		routes:     r.routes,
+		middleware: append(append([]Middleware{}, r.middleware...), middleware...),
+	}
+}
+
+// Handle registers the handler for the method and path pattern
+func (r *Router) Handle(method, pattern string, handler http.Handler) {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+
+	rt, ok := r.routes[pattern]
+	if !ok {
+		rt = &route{handlers: map[string]http.Handler{}}
+		r.routes[pattern] = rt
+		r.mux.Handle(pattern, rt)
+	}
+	rt.handlers[method] = handler
+}
+
+// HandleFunc registers the handler function for the method and path pattern
+func (r *Router) HandleFunc(method, pattern string, handler http.HandlerFunc) {
+	r.Handle(method, pattern, handler)
+}
+
+// ServeHTTP dispatches the request to the handler of its path and method
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// ServeHTTP dispatches the request to the handler of its method
+func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := rt.handlers[r.Method]; ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	// HEAD is served by the GET handler; the server discards the body
+	if r.Method == http.MethodHead {
+		if handler, ok := rt.handlers[http.MethodGet]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Allow", rt.allow())
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+// allow lists the methods supported by the route
+func (rt *route) allow() string {
+	methods := []string{http.MethodOptions}
+	for method := range rt.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := rt.handlers[http.MethodGet]; ok {
+		if _, ok := rt.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// respondWithError responds with an error message
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	response, _ := json.Marshal(models.ErrorResponse{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/najwa/product-catalog-api/internal/models"
+	"github.com/najwa/product-catalog-api/internal/router"
+)
+
+// tag returns middleware appending its name to the X-Trace response header
+func tag(name string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTestRouter() *router.Router {
+	r := router.New()
+	r.HandleFunc(http.MethodGet, "/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("list"))
+	})
+	r.HandleFunc(http.MethodGet, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item " + r.PathValue("id")))
+	})
+
+	protected := r.With(tag("outer"), tag("inner"))
+	protected.HandleFunc(http.MethodPost, "/items", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	protected.HandleFunc(http.MethodDelete, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("deleted " + r.PathValue("id")))
+	})
+	return r
+}
+
+func serve(r http.Handler, method, url string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRouting(t *testing.T) {
+	r := newTestRouter()
+
+	testCases := []struct {
+		name         string
+		method       string
+		url          string
+		expectedCode int
+		expectedBody string
+		expectedTags string
+	}{
+		{"List", http.MethodGet, "/items", http.StatusOK, "list", ""},
+		{"Path parameter", http.MethodGet, "/items/42", http.StatusOK, "item 42", ""},
+		{"Middleware chain", http.MethodPost, "/items", http.StatusCreated, "", "outer,inner"},
+		{"Middleware with path parameter", http.MethodDelete, "/items/7", http.StatusOK, "deleted 7", "outer,inner"},
+		{"HEAD served by GET", http.MethodHead, "/items", http.StatusOK, "list", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(r, tc.method, tc.url)
+			if rr.Code != tc.expectedCode {
+				t.Errorf("Expected status %d, got %d", tc.expectedCode, rr.Code)
+			}
+			if body := rr.Body.String(); body != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, body)
+			}
+			if tags := strings.Join(rr.Header().Values("X-Trace"), ","); tags != tc.expectedTags {
+				t.Errorf("Expected middleware %q, got %q", tc.expectedTags, tags)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := newTestRouter()
+
+	testCases := []struct {
+		url           string
+		expectedAllow string
+	}{
+		{"/items", "GET, HEAD, OPTIONS, POST"},
+		{"/items/1", "DELETE, GET, HEAD, OPTIONS"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			rr := serve(r, http.MethodPut, tc.url)
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+			}
+			if allow := rr.Header().Get("Allow"); allow != tc.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tc.expectedAllow, allow)
+			}
+
+			var response models.ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error == "" {
+				t.Errorf("Expected a JSON error response, got %q", rr.Body.String())
+			}
+
+			// OPTIONS reports the same methods without an error
+			rr = serve(r, http.MethodOptions, tc.url)
+			if rr.Code != http.StatusNoContent {
+				t.Errorf("Expected status %d for OPTIONS, got %d", http.StatusNoContent, rr.Code)
+			}
+			if allow := rr.Header().Get("Allow"); allow != tc.expectedAllow {
+				t.Errorf("Expected Allow %q for OPTIONS, got %q", tc.expectedAllow, allow)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	r := newTestRouter()
+
+	for _, url := range []string{"/", "/missing", "/items/1/extra"} {
+		rr := serve(r, http.MethodGet, url)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", url, http.StatusNotFound, rr.Code)
+		}
+		if rr.Header().Get("Allow") != "" {
+			t.Errorf("%s: expected no Allow header", url)
+		}
+	}
+}
+
+func TestRoutersAreIndependent(t *testing.T) {
+	first := router.New()
+	first.HandleFunc(http.MethodGet, "/only-first", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Routers share no global state
+	second := router.New()
+	if rr := serve(second, http.MethodGet, "/only-first"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(first, http.MethodGet, "/only-first"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
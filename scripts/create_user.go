@@ -0,0 +1,79 @@
+//go:build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/najwa/product-catalog-api/internal/config"
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// create_user creates a user with the given role, or changes the role of an
+// existing user when -promote is set.
+//
+//	go run ./scripts/create_user.go -username alice -password s3cret -role admin
+//	go run ./scripts/create_user.go -username bob -role editor -promote
+func main() {
+	// Parse command-line flags
+	username := flag.String("username", "", "Username of the user")
+	password := flag.String("password", "", "Password of the new user")
+	role := flag.String("role", models.RoleUser, "Role of the user (user, editor or admin)")
+	promote := flag.Bool("promote", false, "Change the role of an existing user instead of creating one")
+
+	// Load the configuration for the database
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if *username == "" {
+		log.Fatal("-username is required")
+	}
+	if !models.ValidRole(*role) {
+		log.Fatalf("Invalid role %q", *role)
+	}
+
+	// Initialize the configured database; the memory driver would lose
+	// everything on exit
+	if cfg.Database.Driver == "memory" {
+		log.Fatal("The memory driver keeps no data between runs; use sqlite or postgres")
+	}
+	source, err := cfg.Database.Source()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := cfg.StoreOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := db.Open(cfg.Database.Driver, source, options)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer store.Close()
+
+	if *promote {
+		user, err := store.GetUserByUsername(*username)
+		if err != nil {
+			log.Fatalf("Error finding user %s: %v", *username, err)
+		}
+		if err := store.SetUserRole(user.ID, *role); err != nil {
+			log.Fatalf("Error updating user %s: %v", *username, err)
+		}
+		log.Printf("User %s now has role %s", *username, *role)
+		return
+	}
+
+	if *password == "" {
+		log.Fatal("-password is required")
+	}
+	user, err := store.CreateUser(*username, *password, *role)
+	if err != nil {
+		log.Fatalf("Error creating user %s: %v", *username, err)
+	}
+	log.Printf("Created user %s (id %d) with role %s", user.Username, user.ID, user.Role)
+}
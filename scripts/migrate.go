@@ -0,0 +1,99 @@
+//go:build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/najwa/product-catalog-api/internal/config"
+	"github.com/najwa/product-catalog-api/internal/db"
+)
+
+// migrate applies, reverts or lists the schema migrations of the configured
+// database.
+//
+//	go run ./scripts/migrate.go up
+//	go run ./scripts/migrate.go down [steps]
+//	go run ./scripts/migrate.go -db-driver postgres -db-dsn postgres://localhost/catalog status
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: migrate [flags] up | down [steps] | status")
+		flag.PrintDefaults()
+	}
+
+	// Load the configuration for the database
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Connect without migrating
+	source, err := cfg.Database.Source()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := db.OpenMigrator(cfg.Database.Driver, source, cfg.Database.Options())
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer migrator.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("Applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error migrating: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date")
+		}
+
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			log.Printf("Reverted %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error reverting: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert")
+		}
+
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Error reading migrations: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,179 @@
+package seed
+
+import (
+	"log"
+
+	"github.com/najwa/product-catalog-api/internal/db"
+	"github.com/najwa/product-catalog-api/internal/models"
+)
+
+// Sample category tree; parents come before their subcategories
+var categories = []models.Category{
+	{Slug: "electronics", Name: "Electronics"},
+	{Slug: "apparel", Name: "Apparel"},
+	{Slug: "clothing", Name: "Clothing", Parent: "apparel"},
+	{Slug: "footwear", Name: "Footwear", Parent: "apparel"},
+	{Slug: "accessories", Name: "Accessories", Parent: "apparel"},
+}
+
+// Sample products data
+var products = []struct {
+	Title       string
+	Description string
+	Price       float64
+	Category    string
+	Image       string
+	Stock       int
+	Rating      float64
+}{
+	{
+		Title:       "Smartphone X",
+		Description: "6.5-inch OLED display with a triple camera",
+		Price:       999.99,
+		Category:    "electronics",
+		Image:       "https://example.com/smartphone.jpg",
+		Stock:       25,
+		Rating:      4.5,
+	},
+	{
+		Title:       "Laptop Pro",
+		Description: "14-inch notebook with 16 GB of memory and all-day battery life",
+		Price:       1499.99,
+		Category:    "electronics",
+		Image:       "https://example.com/laptop.jpg",
+		Stock:       8,
+		Rating:      4.7,
+	},
+	{
+		Title:       "Wireless Headphones",
+		Description: "Over-ear Bluetooth headphones with noise cancellation",
+		Price:       199.99,
+		Category:    "electronics",
+		Image:       "https://example.com/headphones.jpg",
+		Stock:       40,
+		Rating:      4.2,
+	},
+	{
+		Title:       "Smart Watch",
+		Description: "Tracks heart rate, sleep and workouts; water resistant",
+		Price:       299.99,
+		Category:    "electronics",
+		Image:       "https://example.com/smartwatch.jpg",
+		Stock:       0,
+		Rating:      3.9,
+	},
+	{
+		Title:       "Cotton T-Shirt",
+		Description: "Crew neck shirt in soft organic cotton",
+		Price:       19.99,
+		Category:    "clothing",
+		Image:       "https://example.com/tshirt.jpg",
+		Stock:       120,
+		Rating:      4.4,
+	},
+	{
+		Title:       "Jeans",
+		Description: "Slim fit denim with a little stretch",
+		Price:       49.99,
+		Category:    "clothing",
+		Image:       "https://example.com/jeans.jpg",
+		Stock:       60,
+		Rating:      4.1,
+	},
+	{
+		Title:       "Running Shoes",
+		Description: "Lightweight cushioned shoes for road running",
+		Price:       89.99,
+		Category:    "footwear",
+		Image:       "https://example.com/shoes.jpg",
+		Stock:       15,
+		Rating:      4.6,
+	},
+	{
+		Title:       "Backpack",
+		Description: "25-liter pack with a padded laptop sleeve",
+		Price:       39.99,
+		Category:    "accessories",
+		Image:       "https://example.com/backpack.jpg",
+		Stock:       30,
+		Rating:      3.8,
+	},
+	{
+		Title:       "Water Bottle",
+		Description: "Insulated steel bottle that keeps drinks cold for 24 hours",
+		Price:       14.99,
+		Category:    "accessories",
+		Image:       "https://example.com/bottle.jpg",
+		Stock:       200,
+		Rating:      4.3,
+	},
+	{
+		Title:       "Fitness Tracker",
+		Description: "Slim band counting steps, heart rate and sleep",
+		Price:       79.99,
+		Category:    "electronics",
+		Image:       "https://example.com/tracker.jpg",
+		Stock:       0,
+		Rating:      4,
+	},
+}
+
+// Sample user data
+var users = []struct {
+	Username string
+	Password string
+	Role     string
+}{
+	{
+		Username: "john",
+		Password: "1234",
+		Role:     models.RoleUser,
+	},
+	{
+		Username: "admin",
+		Password: "admin1234",
+		Role:     models.RoleAdmin,
+	},
+}
+
+// SeedDatabase seeds the store with sample data
+func SeedDatabase(store db.Store) {
+	// Seed users
+	for _, user := range users {
+		_, err := store.CreateUser(user.Username, user.Password, user.Role)
+		if err != nil {
+			log.Printf("Error seeding user %s: %v", user.Username, err)
+		} else {
+			log.Printf("Seeded user: %s (%s)", user.Username, user.Role)
+		}
+	}
+
+	// Seed categories
+	for _, category := range categories {
+		if _, err := store.CreateCategory(category); err != nil {
+			log.Printf("Error seeding category %s: %v", category.Slug, err)
+		} else {
+			log.Printf("Seeded category: %s", category.Slug)
+		}
+	}
+
+	// Seed products
+	for _, product := range products {
+		_, err := store.CreateProduct(models.Product{
+			Title:       product.Title,
+			Description: product.Description,
+			Price:       product.Price,
+			Category:    product.Category,
+			Image:       product.Image,
+			Stock:       product.Stock,
+			Rating:      product.Rating,
+		})
+		if err != nil {
+			log.Printf("Error seeding product %s: %v", product.Title, err)
+		} else {
+			log.Printf("Seeded product: %s", product.Title)
+		}
+	}
+
+	log.Println("Database seeded successfully!")
+}
@@ -1,9 +1,42 @@
+//go:build ignore
+
 package main
 
 import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/najwa/product-catalog-api/internal/config"
+	"github.com/najwa/product-catalog-api/internal/db"
 	"github.com/najwa/product-catalog-api/scripts/seed"
 )
 
 func main() {
-	seed.SeedDatabase()
+	// Load the configuration for the database
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	// Initialize the configured database; the memory driver would lose
+	// everything on exit
+	if cfg.Database.Driver == "memory" {
+		log.Fatal("The memory driver keeps no data between runs; use sqlite or postgres")
+	}
+	source, err := cfg.Database.Source()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := cfg.StoreOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := db.Open(cfg.Database.Driver, source, options)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer store.Close()
+
+	seed.SeedDatabase(store)
 }